@@ -0,0 +1,151 @@
+package alerts
+
+import (
+	"auditor/events"
+	logFacility "auditor/logger"
+	"auditor/metrics"
+	"auditor/model"
+	"auditor/notify"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const newDestinationRule = "new-destination"
+
+type AlertsConfiguration struct {
+	RulesFile *string
+}
+
+// Engine evaluates the rules against every enriched action and stores the
+// alerts they fire.
+type Engine struct {
+	logger   *logFacility.Logger
+	model    *model.Model
+	notifier *notify.Notifier
+	rules    []*rule
+}
+
+func New(logger *logFacility.Logger, model *model.Model, notifier *notify.Notifier, alertsConf *AlertsConfiguration) (*Engine, error) {
+	toReturn := &Engine{
+		logger:   logger,
+		model:    model,
+		notifier: notifier,
+		rules:    make([]*rule, 0),
+	}
+
+	if alertsConf.RulesFile == nil || strings.EqualFold(*alertsConf.RulesFile, "") {
+		logger.Log.Info("No alert rules configured")
+		return toReturn, nil
+	}
+
+	rules, err := readRules(*alertsConf.RulesFile)
+	if err != nil {
+		return nil, err
+	}
+	toReturn.rules = rules
+	logger.Log.Infof("Loaded %d alert rules", len(rules))
+
+	return toReturn, nil
+}
+
+func (e *Engine) Evaluate(event *events.Event) {
+	var sourceLabels *model.Labels
+	labelsResolved := false
+	resolveSourceLabels := func() *model.Labels {
+		if !labelsResolved {
+			labels, err := e.model.ResolveLabels(*event.Action.SrcAddr)
+			if err != nil && !errors.Is(err, model.LabelsNotFoundErr) {
+				e.logger.Log.Warn(err)
+			}
+			sourceLabels = labels
+			labelsResolved = true
+		}
+
+		return sourceLabels
+	}
+
+	for _, aRule := range e.rules {
+		if !aRule.matches(event, resolveSourceLabels) {
+			continue
+		}
+
+		e.Fire(&model.Alert{
+			Rule:        aRule.name,
+			Severity:    aRule.severity,
+			Time:        event.Time,
+			Description: describe(event),
+			Action:      event.Action,
+		}, strings.Join([]string{aRule.name, *event.Action.SrcAddr, *event.Action.DstAddr}, "|"), aRule.dedupWindow)
+	}
+}
+
+// Fire stores an alert unless the same dedupKey fired within the window, it is
+// used by the detectors that do not work on single actions too.
+func (e *Engine) Fire(alert *model.Alert, dedupKey string, window time.Duration) {
+	if alert.Time.IsZero() {
+		alert.Time = time.Now()
+	}
+
+	fired, err := e.model.FireAlert(alert, dedupKey, window)
+	if err != nil {
+		e.logger.Log.Warn(err)
+		return
+	}
+
+	if !fired {
+		e.logger.Log.Debugf("Alert %s already fired for %s", alert.Rule, dedupKey)
+		return
+	}
+
+	metrics.AlertsFired.WithLabelValues(alert.Rule, alert.Severity.String()).Inc()
+	e.logger.Log.Warnf("Alert %s [%s]: %s", alert.Rule, alert.Severity, alert.Description)
+	e.notifier.Notify(alert)
+}
+
+// FromNewDestinations fires an info alert for every destination a source
+// started talking to after its learning period.
+func (e *Engine) FromNewDestinations(newDestinations chan *model.NewDestination) {
+	for newDestination := range newDestinations {
+		source := newDestination.Source
+		destination := newDestination.Destination
+		action := &model.Action{
+			SrcAddr: &source,
+			DstAddr: &destination,
+		}
+
+		description := fmt.Sprintf("%s contacted %s for the first time", source, destination)
+		if len(newDestination.Hostnames) > 0 {
+			action.Hostname = &newDestination.Hostnames[0]
+			description = fmt.Sprintf("%s contacted %s (%s) for the first time", source, strings.Join(newDestination.Hostnames, ", "), destination)
+		}
+
+		e.Fire(&model.Alert{
+			Rule:        newDestinationRule,
+			Severity:    model.Info,
+			Time:        newDestination.Time,
+			Description: description,
+			Action:      action,
+		}, strings.Join([]string{newDestinationRule, source, destination, strings.Join(newDestination.Hostnames, ",")}, "|"), defaultDedupWindow)
+	}
+}
+
+func describe(event *events.Event) string {
+	destination := *event.Action.DstAddr
+	if event.Action.Hostname != nil {
+		destination = fmt.Sprintf("%s (%s)", *event.Action.Hostname, destination)
+	} else if event.DstMeta != nil && len(event.DstMeta.Hostnames) > 0 {
+		destination = fmt.Sprintf("%s (%s)", event.DstMeta.Hostnames[0], destination)
+	}
+
+	if event.Action.DstPort != nil {
+		destination = fmt.Sprintf("%s port %d", destination, *event.Action.DstPort)
+	}
+
+	if event.DstMeta != nil && event.DstMeta.Country != nil && *event.DstMeta.Country != "" {
+		destination = fmt.Sprintf("%s in %s", destination, strings.ToUpper(*event.DstMeta.Country))
+	}
+
+	return fmt.Sprintf("%s contacted %s", *event.Action.SrcAddr, destination)
+}
@@ -0,0 +1,341 @@
+package alerts
+
+import (
+	"auditor/events"
+	"auditor/model"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const defaultDedupWindow = time.Hour
+
+// RuleDefinition is how rules are written in the rules file, a json array of
+// them. Every condition of Match that is set must hold for the rule to fire.
+type RuleDefinition struct {
+	Name        string          `json:"name"`
+	Severity    model.Severity  `json:"severity"`
+	DedupWindow string          `json:"dedupWindow,omitempty"`
+	Match       MatchDefinition `json:"match"`
+}
+
+type MatchDefinition struct {
+	SourceCidrs        []string `json:"sourceCidrs,omitempty"`
+	SourceTags         []string `json:"sourceTags,omitempty"`
+	DestinationCidrs   []string `json:"destinationCidrs,omitempty"`
+	DestinationPorts   []uint16 `json:"destinationPorts,omitempty"`
+	Countries          []string `json:"countries,omitempty"`
+	CountriesNotIn     []string `json:"countriesNotIn,omitempty"`
+	HasVulnerabilities *bool    `json:"hasVulnerabilities,omitempty"`
+	Hostnames          []string `json:"hostnames,omitempty"`
+	HostnamesFile      string   `json:"hostnamesFile,omitempty"`
+	MinDgaScore        *float64 `json:"minDgaScore,omitempty"`
+}
+
+type rule struct {
+	name        string
+	severity    model.Severity
+	dedupWindow time.Duration
+
+	sourceCidrs        []*net.IPNet
+	sourceTags         []string
+	destinationCidrs   []*net.IPNet
+	destinationPorts   map[uint16]bool
+	countries          map[string]bool
+	countriesNotIn     map[string]bool
+	hasVulnerabilities *bool
+	hostnames          *hostnames
+	minDgaScore        *float64
+}
+
+func readRules(file string) ([]*rule, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	definitions := make([]*RuleDefinition, 0)
+	if err := json.Unmarshal(content, &definitions); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	toReturn := make([]*rule, 0, len(definitions))
+	names := make(map[string]bool, len(definitions))
+	for _, aDefinition := range definitions {
+		if names[aDefinition.Name] {
+			return nil, fmt.Errorf("%s: rule %s is defined twice", file, aDefinition.Name)
+		}
+		names[aDefinition.Name] = true
+
+		aRule, err := ruleFrom(aDefinition)
+		if err != nil {
+			return nil, fmt.Errorf("%s: rule %s: %w", file, aDefinition.Name, err)
+		}
+
+		toReturn = append(toReturn, aRule)
+	}
+
+	return toReturn, nil
+}
+
+func ruleFrom(definition *RuleDefinition) (*rule, error) {
+	if strings.TrimSpace(definition.Name) == "" {
+		return nil, errors.New("rules need a name")
+	}
+
+	toReturn := &rule{
+		name:               definition.Name,
+		severity:           definition.Severity,
+		dedupWindow:        defaultDedupWindow,
+		sourceTags:         definition.Match.SourceTags,
+		hasVulnerabilities: definition.Match.HasVulnerabilities,
+		minDgaScore:        definition.Match.MinDgaScore,
+	}
+
+	if definition.DedupWindow != "" {
+		window, err := time.ParseDuration(definition.DedupWindow)
+		if err != nil {
+			return nil, err
+		}
+		toReturn.dedupWindow = window
+	}
+
+	var err error
+	if toReturn.sourceCidrs, err = cidrsFrom(definition.Match.SourceCidrs); err != nil {
+		return nil, err
+	}
+
+	if toReturn.destinationCidrs, err = cidrsFrom(definition.Match.DestinationCidrs); err != nil {
+		return nil, err
+	}
+
+	if len(definition.Match.DestinationPorts) > 0 {
+		toReturn.destinationPorts = make(map[uint16]bool)
+		for _, aPort := range definition.Match.DestinationPorts {
+			toReturn.destinationPorts[aPort] = true
+		}
+	}
+
+	toReturn.countries = lowerSet(definition.Match.Countries)
+	toReturn.countriesNotIn = lowerSet(definition.Match.CountriesNotIn)
+
+	patterns := definition.Match.Hostnames
+	if definition.Match.HostnamesFile != "" {
+		fromFile, err := readHostnames(definition.Match.HostnamesFile)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, fromFile...)
+	}
+	if len(patterns) > 0 {
+		toReturn.hostnames = newHostnames(patterns)
+	}
+
+	return toReturn, nil
+}
+
+func (r *rule) matches(event *events.Event, sourceLabels func() *model.Labels) bool {
+	srcAddr := net.ParseIP(*event.Action.SrcAddr)
+	dstAddr := net.ParseIP(*event.Action.DstAddr)
+
+	if len(r.sourceCidrs) > 0 && !anyContains(r.sourceCidrs, srcAddr) {
+		return false
+	}
+
+	if len(r.destinationCidrs) > 0 && !anyContains(r.destinationCidrs, dstAddr) {
+		return false
+	}
+
+	if r.destinationPorts != nil && (event.Action.DstPort == nil || !r.destinationPorts[*event.Action.DstPort]) {
+		return false
+	}
+
+	var country string
+	if event.DstMeta != nil && event.DstMeta.Country != nil {
+		country = strings.ToLower(*event.DstMeta.Country)
+	}
+
+	if r.countries != nil && !r.countries[country] {
+		return false
+	}
+
+	// destinations without a country, like local ones, are not foreign
+	if r.countriesNotIn != nil && (country == "" || r.countriesNotIn[country]) {
+		return false
+	}
+
+	if r.hasVulnerabilities != nil {
+		vulnerable := event.DstMeta != nil && len(event.DstMeta.Vulnerabilities) > 0
+		if vulnerable != *r.hasVulnerabilities {
+			return false
+		}
+	}
+
+	if r.hostnames != nil && !r.matchesHostnames(event) {
+		return false
+	}
+
+	if r.minDgaScore != nil && (event.HostnameScore == nil || event.HostnameScore.Score < *r.minDgaScore) {
+		return false
+	}
+
+	if len(r.sourceTags) > 0 && !r.matchesTags(sourceLabels()) {
+		return false
+	}
+
+	return true
+}
+
+func (r *rule) matchesHostnames(event *events.Event) bool {
+	if event.Action.Hostname != nil && r.hostnames.matches(*event.Action.Hostname) {
+		return true
+	}
+
+	if event.DstMeta != nil {
+		for _, aHostname := range event.DstMeta.Hostnames {
+			if r.hostnames.matches(aHostname) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (r *rule) matchesTags(labels *model.Labels) bool {
+	if labels == nil {
+		return false
+	}
+
+	for _, aTag := range r.sourceTags {
+		if strings.EqualFold(aTag, labels.Name) {
+			return true
+		}
+
+		for _, aLabelTag := range labels.Tags {
+			if strings.EqualFold(aTag, aLabelTag) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// hostnames matches globs, like *.tiktokcdn.com, and domains, which match
+// their subdomains too.
+type hostnames struct {
+	domains map[string]bool
+	globs   []string
+}
+
+func newHostnames(patterns []string) *hostnames {
+	toReturn := &hostnames{
+		domains: make(map[string]bool),
+	}
+
+	for _, aPattern := range patterns {
+		aPattern = strings.Trim(strings.ToLower(strings.TrimSpace(aPattern)), ".")
+		if aPattern == "" {
+			continue
+		}
+
+		if strings.ContainsAny(aPattern, "*?[") {
+			toReturn.globs = append(toReturn.globs, aPattern)
+		} else {
+			toReturn.domains[aPattern] = true
+		}
+	}
+
+	return toReturn
+}
+
+func (h *hostnames) matches(hostname string) bool {
+	hostname = strings.Trim(strings.ToLower(hostname), ".")
+
+	for domain := hostname; domain != ""; {
+		if h.domains[domain] {
+			return true
+		}
+
+		dot := strings.Index(domain, ".")
+		if dot < 0 {
+			break
+		}
+		domain = domain[dot+1:]
+	}
+
+	for _, aGlob := range h.globs {
+		if matched, err := path.Match(aGlob, hostname); err == nil && matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readHostnames reads a domain or glob per line, hosts file lines like
+// "0.0.0.0 ads.example.com" are accepted too.
+func readHostnames(file string) ([]string, error) {
+	reader, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	toReturn := make([]string, 0)
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		toReturn = append(toReturn, fields[len(fields)-1])
+	}
+
+	return toReturn, scanner.Err()
+}
+
+func cidrsFrom(values []string) ([]*net.IPNet, error) {
+	toReturn := make([]*net.IPNet, 0, len(values))
+	for _, aValue := range values {
+		_, network, err := net.ParseCIDR(strings.TrimSpace(aValue))
+		if err != nil {
+			return nil, err
+		}
+		toReturn = append(toReturn, network)
+	}
+
+	return toReturn, nil
+}
+
+func anyContains(networks []*net.IPNet, ip net.IP) bool {
+	for _, aNetwork := range networks {
+		if aNetwork.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func lowerSet(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+
+	toReturn := make(map[string]bool, len(values))
+	for _, aValue := range values {
+		toReturn[strings.ToLower(strings.TrimSpace(aValue))] = true
+	}
+
+	return toReturn
+}
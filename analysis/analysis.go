@@ -0,0 +1,123 @@
+package analysis
+
+import (
+	"auditor/alerts"
+	logFacility "auditor/logger"
+	"auditor/model"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	beaconingRule        = "beaconing"
+	beaconingDedupWindow = 24 * time.Hour
+)
+
+type AnalysisConfiguration struct {
+	BeaconsInterval    *time.Duration
+	BeaconsBurstWindow *time.Duration
+	BeaconsMinScore    *float64
+}
+
+// Analyzer periodically looks at the stored traffic for behaviours that single
+// actions do not show.
+type Analyzer struct {
+	logger          *logFacility.Logger
+	model           *model.Model
+	alerts          *alerts.Engine
+	beaconsMinScore float64
+	burstWindow     int64
+
+	ticker      *time.Ticker
+	tickersDone chan bool
+}
+
+func New(logger *logFacility.Logger, model *model.Model, alerts *alerts.Engine, analysisConf *AnalysisConfiguration) *Analyzer {
+	return &Analyzer{
+		logger:          logger,
+		model:           model,
+		alerts:          alerts,
+		beaconsMinScore: *analysisConf.BeaconsMinScore,
+		burstWindow:     int64(analysisConf.BeaconsBurstWindow.Seconds()),
+		ticker:          time.NewTicker(*analysisConf.BeaconsInterval),
+		tickersDone:     make(chan bool),
+	}
+}
+
+func (a *Analyzer) Run() {
+	for {
+		select {
+		case <-a.tickersDone:
+			return
+		case <-a.ticker.C:
+			a.findBeacons()
+		}
+	}
+}
+
+func (a *Analyzer) Dispose() {
+	a.ticker.Stop()
+	close(a.tickersDone)
+}
+
+func (a *Analyzer) findBeacons() {
+	allContacts, err := a.model.ListContacts()
+	if err != nil {
+		a.logger.Log.Warn(err)
+		return
+	}
+
+	// contacts are pruned when merged, the ones of idle sources are skipped
+	oldest := time.Now().Add(-model.ContactsRetention).Unix()
+	beacons := make([]*model.Beacon, 0)
+	for _, someContacts := range allContacts {
+		for _, aContact := range someContacts.Contacts {
+			if len(aContact.Times) == 0 || aContact.Times[len(aContact.Times)-1] < oldest {
+				continue
+			}
+
+			aBeacon := beaconFrom(*someContacts.Ip, aContact, oldest, a.burstWindow)
+			if aBeacon == nil {
+				continue
+			}
+			beacons = append(beacons, aBeacon)
+
+			if aBeacon.Score >= a.beaconsMinScore {
+				a.fireBeaconing(aBeacon)
+			}
+		}
+	}
+
+	if err := a.model.StoreBeacons(beacons); err != nil {
+		a.logger.Log.Warn(err)
+		return
+	}
+	a.logger.Log.Debugf("Found %d periodic connections", len(beacons))
+}
+
+func (a *Analyzer) fireBeaconing(beacon *model.Beacon) {
+	source := beacon.Source
+	destination := beacon.Destination
+	action := &model.Action{
+		SrcAddr: &source,
+		DstAddr: &destination,
+	}
+
+	target := destination
+	if beacon.Hostname != "" {
+		hostname := beacon.Hostname
+		action.Hostname = &hostname
+		target = fmt.Sprintf("%s (%s)", hostname, destination)
+	}
+
+	interval := time.Duration(beacon.IntervalSeconds) * time.Second
+	jitter := time.Duration(beacon.JitterSeconds) * time.Second
+	a.alerts.Fire(&model.Alert{
+		Rule:     beaconingRule,
+		Severity: model.Medium,
+		Description: fmt.Sprintf("%s contacts %s every %s with %s of jitter, %d times since %s (score %.2f)",
+			source, target, interval, jitter, beacon.Connections, beacon.FirstSeen.Format(time.RFC3339), beacon.Score),
+		Action: action,
+	}, strings.Join([]string{beaconingRule, source, target}, "|"), beaconingDedupWindow)
+}
@@ -0,0 +1,86 @@
+package analysis
+
+import (
+	"auditor/model"
+	"math"
+	"sort"
+	"time"
+)
+
+// DefaultBurstWindow is the time within which contacts are the same
+// connection split in more flows.
+const DefaultBurstWindow = 2 * time.Second
+
+const (
+	minConnections       = 8
+	confidentConnections = 24
+	minStoredScore       = 0.3
+)
+
+// beaconFrom scores how periodic the contacts since oldest are, it returns nil
+// when there are too few of them to tell.
+func beaconFrom(source string, contact *model.Contact, oldest int64, burstWindow int64) *model.Beacon {
+	times := collapseBursts(contact.Times, oldest, burstWindow)
+	if len(times) < minConnections {
+		return nil
+	}
+
+	intervals := make([]float64, 0, len(times)-1)
+	for index := 1; index < len(times); index++ {
+		intervals = append(intervals, float64(times[index]-times[index-1]))
+	}
+
+	interval := median(intervals)
+	if interval <= 0 {
+		return nil
+	}
+
+	deviations := make([]float64, 0, len(intervals))
+	for _, anInterval := range intervals {
+		deviations = append(deviations, math.Abs(anInterval-interval))
+	}
+	jitter := median(deviations)
+
+	regularity := 1 - math.Min(1, jitter/interval)
+	confidence := math.Min(1, float64(len(intervals))/float64(confidentConnections-1))
+	score := math.Round(regularity*confidence*1000) / 1000
+	if score < minStoredScore {
+		return nil
+	}
+
+	return &model.Beacon{
+		Source:          source,
+		Destination:     contact.Destination,
+		Hostname:        contact.Hostname,
+		Connections:     len(times),
+		IntervalSeconds: interval,
+		JitterSeconds:   jitter,
+		Score:           score,
+		FirstSeen:       time.Unix(times[0], 0),
+		LastSeen:        time.Unix(times[len(times)-1], 0),
+	}
+}
+
+func collapseBursts(times []int64, oldest int64, burstWindow int64) []int64 {
+	toReturn := make([]int64, 0, len(times))
+	for _, aTime := range times {
+		if aTime < oldest || len(toReturn) > 0 && aTime-toReturn[len(toReturn)-1] < burstWindow {
+			continue
+		}
+		toReturn = append(toReturn, aTime)
+	}
+
+	return toReturn
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+
+	return sorted[middle]
+}
@@ -19,6 +19,7 @@ func registerActionsRoutes(context string, api *Api) {
 
 	actionsRoutes := api.engine.Group(context)
 	actionsRoutes.GET("/:ip", toReturn.actionsByIp)
+	actionsRoutes.GET("/:ip/exporters", toReturn.exportersByIp)
 }
 
 func (a *actions) actionsByIp(c *gin.Context) {
@@ -36,3 +37,23 @@ func (a *actions) actionsByIp(c *gin.Context) {
 
 	c.JSON(http.StatusOK, actions.Traffic)
 }
+
+func (a *actions) exportersByIp(c *gin.Context) {
+	ip := c.Param("ip")
+	actions, actionsErr := a.model.GetActions(ip)
+
+	if errors.Is(actionsErr, model.ActionNotFoundErr) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	if actionsErr != nil {
+		panic(actionsErr)
+	}
+
+	if actions.Exporters == nil {
+		actions.Exporters = make(map[string][]string)
+	}
+
+	c.JSON(http.StatusOK, actions.Exporters)
+}
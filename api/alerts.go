@@ -0,0 +1,86 @@
+package api
+
+import (
+	"auditor/model"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type alerts struct {
+	model *model.Model
+}
+
+func registerAlertsRoutes(context string, api *Api) {
+	toReturn := alerts{
+		model: api.model,
+	}
+
+	alertsRoutes := api.engine.Group(context)
+	alertsRoutes.GET("", toReturn.allAlerts)
+}
+
+func (a *alerts) allAlerts(c *gin.Context) {
+	query, queryErr := alertsQueryFrom(c)
+	if queryErr != nil {
+		c.String(http.StatusBadRequest, queryErr.Error())
+		return
+	}
+
+	alerts, alertsErr := a.model.ListAlerts(query)
+	if alertsErr != nil {
+		panic(alertsErr)
+	}
+
+	c.JSON(http.StatusOK, alerts)
+}
+
+func alertsQueryFrom(c *gin.Context) (*model.AlertsQuery, error) {
+	toReturn := &model.AlertsQuery{
+		Rule:   c.Query("rule"),
+		Source: c.Query("src"),
+	}
+
+	if limit, ok := c.GetQuery("limit"); ok {
+		parsedLimit, err := strconv.Atoi(limit)
+		if err != nil || parsedLimit <= 0 {
+			return nil, fmt.Errorf("limit must be a positive number")
+		}
+		toReturn.Limit = parsedLimit
+	}
+
+	if since, ok := c.GetQuery("since"); ok {
+		parsedSince, err := sinceFrom(since)
+		if err != nil {
+			return nil, err
+		}
+		toReturn.Since = parsedSince
+	}
+
+	if severity, ok := c.GetQuery("severity"); ok {
+		parsedSeverity, err := model.SeverityFrom(severity)
+		if err != nil {
+			return nil, err
+		}
+		toReturn.MinSeverity = parsedSeverity
+	}
+
+	return toReturn, nil
+}
+
+// sinceFrom accepts a RFC3339 time or a duration back from now, like 24h.
+func sinceFrom(value string) (time.Time, error) {
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return time.Time{}, fmt.Errorf("since must be a RFC3339 time or a positive duration")
+	}
+
+	return time.Now().Add(-duration), nil
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"auditor/model"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type analysis struct {
+	model *model.Model
+}
+
+func registerAnalysisRoutes(context string, api *Api) {
+	toReturn := analysis{
+		model: api.model,
+	}
+
+	analysisRoutes := api.engine.Group(context)
+	analysisRoutes.GET("/beacons", toReturn.beacons)
+}
+
+func (a *analysis) beacons(c *gin.Context) {
+	query, queryErr := beaconsQueryFrom(c)
+	if queryErr != nil {
+		c.String(http.StatusBadRequest, queryErr.Error())
+		return
+	}
+
+	beacons, beaconsErr := a.model.ListBeacons(query)
+	if beaconsErr != nil {
+		panic(beaconsErr)
+	}
+
+	c.JSON(http.StatusOK, beacons)
+}
+
+func beaconsQueryFrom(c *gin.Context) (*model.BeaconsQuery, error) {
+	toReturn := &model.BeaconsQuery{
+		Source: c.Query("src"),
+	}
+
+	if limit, ok := c.GetQuery("limit"); ok {
+		parsedLimit, err := strconv.Atoi(limit)
+		if err != nil || parsedLimit <= 0 {
+			return nil, fmt.Errorf("limit must be a positive number")
+		}
+		toReturn.Limit = parsedLimit
+	}
+
+	if minScore, ok := c.GetQuery("minScore"); ok {
+		parsedMinScore, err := strconv.ParseFloat(minScore, 64)
+		if err != nil || parsedMinScore < 0 || parsedMinScore > 1 {
+			return nil, fmt.Errorf("minScore must be a number between 0 and 1")
+		}
+		toReturn.MinScore = parsedMinScore
+	}
+
+	return toReturn, nil
+}
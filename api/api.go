@@ -1,20 +1,39 @@
 package api
 
 import (
+	"auditor/events"
 	logFacility "auditor/logger"
+	"auditor/meta"
+	"auditor/metrics"
 	"auditor/model"
+	"auditor/serving"
+	"strconv"
+	"strings"
 	"time"
 
 	ginzap "github.com/gin-contrib/zap"
 	"github.com/gin-gonic/gin"
 )
 
+type ApiConfiguration struct {
+	TokensFile             *string
+	BasicFile              *string
+	ClientCertificatesFile *string
+
+	Serving *serving.ServingConfiguration
+}
+
 type Api struct {
-	engine *gin.Engine
-	model  *model.Model
+	logger  *logFacility.Logger
+	serving *serving.ServingConfiguration
+
+	engine      *gin.Engine
+	model       *model.Model
+	events      *events.Hub
+	localPolicy *meta.LocalPolicy
 }
 
-func New(logger *logFacility.Logger, model *model.Model) (*Api, error) {
+func New(logger *logFacility.Logger, model *model.Model, events *events.Hub, localPolicy *meta.LocalPolicy, apiConf *ApiConfiguration) (*Api, error) {
 	desugaredZap := logger.Log.Desugar()
 
 	engine := gin.New()
@@ -24,19 +43,60 @@ func New(logger *logFacility.Logger, model *model.Model) (*Api, error) {
 	ginzapLogger := ginzap.Ginzap(desugaredZap, time.RFC3339, true)
 	ginzapRecovery := ginzap.RecoveryWithZap(desugaredZap, true)
 
-	engine.Use(ginzapLogger, ginzapRecovery)
+	engine.Use(ginzapLogger, ginzapRecovery, requestDuration)
+
+	authenticators, err := authenticatorsFrom(apiConf)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(authenticators) > 0 {
+		challenge := `Bearer realm="auditor"`
+		if apiConf.BasicFile != nil && !strings.EqualFold(*apiConf.BasicFile, "") {
+			challenge = `Basic realm="auditor"`
+		}
+
+		engine.Use(authentication(authenticators, challenge))
+	} else {
+		logger.Log.Warn("No api authentication configured, every client can read the gathered data")
+	}
 
 	toReturn := &Api{
-		engine: engine,
-		model:  model,
+		logger:  logger,
+		serving: apiConf.Serving,
+
+		engine:      engine,
+		model:       model,
+		events:      events,
+		localPolicy: localPolicy,
 	}
 
 	registerIpsRoutes("/ip", toReturn)
 	registerActionsRoutes("/actions", toReturn)
+	registerAlertsRoutes("/alerts", toReturn)
+	registerAnalysisRoutes("/analysis", toReturn)
+	registerDevicesRoutes("/devices", toReturn)
+	registerHostnamesRoutes("/hostnames", toReturn)
+	registerEventsRoutes("/events", toReturn)
+	registerExportsRoutes("/exports", toReturn)
+	registerSearchRoutes("/search", toReturn)
+	registerTrackersRoutes("/trackers", toReturn)
+	registerOpenApiRoutes("/openapi.json", toReturn)
 
 	return toReturn, nil
 }
 
-func (a *Api) Up() {
-	a.engine.Run(":3000")
+func requestDuration(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	metrics.ApiRequestDuration.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Observe(time.Since(start).Seconds())
+}
+
+func (a *Api) Up() error {
+	return serving.Serve(a.logger, "api", a.serving, a.engine)
 }
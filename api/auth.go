@@ -0,0 +1,82 @@
+package api
+
+import (
+	"auditor/auth"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const identityKey = "identity"
+
+func authenticatorsFrom(apiConf *ApiConfiguration) ([]auth.Authenticator, error) {
+	if apiConf.ClientCertificatesFile != nil && !strings.EqualFold(*apiConf.ClientCertificatesFile, "") && !apiConf.Serving.VerifiesClients() {
+		return nil, errors.New("client certificates authentication needs tls and a client certificate authority")
+	}
+
+	toReturn := make([]auth.Authenticator, 0)
+
+	factories := []struct {
+		file    *string
+		factory func(string) (auth.Authenticator, error)
+	}{
+		{apiConf.ClientCertificatesFile, auth.NewClientCertificates},
+		{apiConf.TokensFile, auth.NewTokens},
+		{apiConf.BasicFile, auth.NewBasic},
+	}
+
+	for _, aFactory := range factories {
+		if aFactory.file == nil || strings.EqualFold(*aFactory.file, "") {
+			continue
+		}
+
+		authenticator, err := aFactory.factory(*aFactory.file)
+		if err != nil {
+			return nil, err
+		}
+		toReturn = append(toReturn, authenticator)
+	}
+
+	return toReturn, nil
+}
+
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// authentication lets read-only identities use safe methods only, everything
+// that mutates state requires the admin role.
+func authentication(authenticators []auth.Authenticator, challenge string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, authenticator := range authenticators {
+			identity, err := authenticator.Authenticate(c.Request)
+			if errors.Is(err, auth.InvalidCredentialsErr) {
+				c.Header("WWW-Authenticate", challenge)
+				c.AbortWithStatus(http.StatusUnauthorized)
+				return
+			}
+
+			if err != nil {
+				panic(err)
+			}
+
+			if identity == nil {
+				continue
+			}
+
+			if identity.Role != auth.Admin && !isReadOnlyMethod(c.Request.Method) {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+
+			c.Set(identityKey, identity)
+			c.Next()
+			return
+		}
+
+		c.Header("WWW-Authenticate", challenge)
+		c.AbortWithStatus(http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"auditor/model"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultCategoriesSince = 7 * 24 * time.Hour
+
+type devices struct {
+	model *model.Model
+}
+
+func registerDevicesRoutes(context string, api *Api) {
+	toReturn := devices{
+		model: api.model,
+	}
+
+	devicesRoutes := api.engine.Group(context)
+	devicesRoutes.GET("", toReturn.allDevices)
+	devicesRoutes.GET("/:mac/actions", toReturn.actionsByDevice)
+	devicesRoutes.GET("/:mac/categories", toReturn.categoriesByDevice)
+	devicesRoutes.GET("/:mac/trackers", toReturn.trackersByDevice)
+	devicesRoutes.GET("/:mac/labels", toReturn.labelsByDevice)
+	devicesRoutes.PUT("/:mac/labels", toReturn.setLabels)
+	devicesRoutes.DELETE("/:mac/labels", toReturn.deleteLabels)
+}
+
+func (d *devices) allDevices(c *gin.Context) {
+	filter := &model.DevicesFilter{}
+	filter.Label, filter.Owner = labelsFilterFrom(c)
+
+	devices, devicesErr := d.model.ListDevices(filter)
+	if devicesErr != nil {
+		panic(devicesErr)
+	}
+
+	c.JSON(http.StatusOK, devices)
+}
+
+func (d *devices) actionsByDevice(c *gin.Context) {
+	mac, macErr := net.ParseMAC(c.Param("mac"))
+	if macErr != nil {
+		c.String(http.StatusBadRequest, macErr.Error())
+		return
+	}
+
+	actions, actionsErr := d.model.GetDeviceActions(mac.String())
+	if errors.Is(actionsErr, model.DeviceNotFoundErr) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	if actionsErr != nil {
+		panic(actionsErr)
+	}
+
+	c.JSON(http.StatusOK, actions.Traffic)
+}
+
+func (d *devices) categoriesByDevice(c *gin.Context) {
+	mac, macErr := net.ParseMAC(c.Param("mac"))
+	if macErr != nil {
+		c.String(http.StatusBadRequest, macErr.Error())
+		return
+	}
+
+	since := time.Now().Add(-defaultCategoriesSince)
+	if value, ok := c.GetQuery("since"); ok {
+		parsedSince, err := sinceFrom(value)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		since = parsedSince
+	}
+
+	bucket := model.CategoriesBucket
+	if value, ok := c.GetQuery("bucket"); ok {
+		parsedBucket, err := time.ParseDuration(value)
+		if err != nil || parsedBucket <= 0 || parsedBucket%model.CategoriesBucket != 0 {
+			c.String(http.StatusBadRequest, "bucket must be a duration multiple of %s", model.CategoriesBucket)
+			return
+		}
+		bucket = parsedBucket
+	}
+
+	categories, categoriesErr := d.model.ListDeviceCategories(mac.String(), since, bucket)
+	if errors.Is(categoriesErr, model.DeviceNotFoundErr) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	if categoriesErr != nil {
+		panic(categoriesErr)
+	}
+
+	c.JSON(http.StatusOK, categories)
+}
+
+func (d *devices) trackersByDevice(c *gin.Context) {
+	mac, macErr := net.ParseMAC(c.Param("mac"))
+	if macErr != nil {
+		c.String(http.StatusBadRequest, macErr.Error())
+		return
+	}
+
+	limit, limitErr := trackersLimitFrom(c)
+	if limitErr != nil {
+		c.String(http.StatusBadRequest, limitErr.Error())
+		return
+	}
+
+	trackers, trackersErr := d.model.GetDeviceTrackers(mac.String(), limit)
+	if errors.Is(trackersErr, model.DeviceNotFoundErr) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	if trackersErr != nil {
+		panic(trackersErr)
+	}
+
+	c.JSON(http.StatusOK, trackers)
+}
+
+func (d *devices) labelsByDevice(c *gin.Context) {
+	mac, macErr := net.ParseMAC(c.Param("mac"))
+	if macErr != nil {
+		c.String(http.StatusBadRequest, macErr.Error())
+		return
+	}
+
+	labels, labelsErr := d.model.GetDeviceLabels(mac.String())
+	respondLabels(c, labels, labelsErr)
+}
+
+func (d *devices) setLabels(c *gin.Context) {
+	mac, macErr := net.ParseMAC(c.Param("mac"))
+	if macErr != nil {
+		c.String(http.StatusBadRequest, macErr.Error())
+		return
+	}
+
+	labels, labelsErr := labelsFromBody(c)
+	if labelsErr != nil {
+		c.String(http.StatusBadRequest, labelsErr.Error())
+		return
+	}
+
+	respondLabels(c, labels, d.model.SetDeviceLabels(mac.String(), labels))
+}
+
+func (d *devices) deleteLabels(c *gin.Context) {
+	mac, macErr := net.ParseMAC(c.Param("mac"))
+	if macErr != nil {
+		c.String(http.StatusBadRequest, macErr.Error())
+		return
+	}
+
+	respondLabels(c, nil, d.model.DeleteDeviceLabels(mac.String()))
+}
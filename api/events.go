@@ -0,0 +1,134 @@
+package api
+
+import (
+	"auditor/events"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"path"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+type eventsStream struct {
+	events *events.Hub
+}
+
+func registerEventsRoutes(context string, api *Api) {
+	toReturn := eventsStream{
+		events: api.events,
+	}
+
+	eventsRoutes := api.engine.Group(context)
+	eventsRoutes.GET("", toReturn.serverSentEvents)
+	eventsRoutes.GET("/ws", toReturn.webSocket)
+}
+
+func filterFromQuery(c *gin.Context) (*events.Filter, error) {
+	toReturn := &events.Filter{
+		Hostname: c.Query("hostname"),
+		Country:  c.Query("country"),
+	}
+
+	if source, ok := c.GetQuery("src"); ok {
+		toReturn.Source = net.ParseIP(source)
+		if toReturn.Source == nil {
+
+			return nil, errors.New("src is not a valid ip address")
+		}
+	}
+
+	if cidr, ok := c.GetQuery("cidr"); ok {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+
+			return nil, err
+		}
+		toReturn.Cidr = network
+	}
+
+	if _, err := path.Match(toReturn.Hostname, ""); err != nil {
+
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func (e *eventsStream) serverSentEvents(c *gin.Context) {
+	filter, filterErr := filterFromQuery(c)
+	if filterErr != nil {
+		c.String(http.StatusBadRequest, filterErr.Error())
+		return
+	}
+
+	subscription := e.events.Subscribe(filter)
+	defer e.events.Unsubscribe(subscription)
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, ok := <-subscription.C:
+			if !ok {
+				return false
+			}
+
+			c.SSEvent("action", event)
+			return true
+		}
+	})
+}
+
+func (e *eventsStream) webSocket(c *gin.Context) {
+	filter, filterErr := filterFromQuery(c)
+	if filterErr != nil {
+		c.String(http.StatusBadRequest, filterErr.Error())
+		return
+	}
+
+	// websocket.Server, unlike websocket.Handler, accepts clients that do not
+	// send an Origin header, like scripts and other services, browsers must
+	// still come from the same host.
+	server := websocket.Server{Handshake: sameOrigin, Handler: func(conn *websocket.Conn) {
+		subscription := e.events.Subscribe(filter)
+		defer e.events.Unsubscribe(subscription)
+
+		closed := make(chan bool)
+		go func() {
+			var discard []byte
+			for websocket.Message.Receive(conn, &discard) == nil {
+			}
+			close(closed)
+		}()
+
+		for {
+			select {
+			case <-closed:
+				return
+			case event, ok := <-subscription.C:
+				if !ok {
+					return
+				}
+
+				if err := websocket.JSON.Send(conn, event); err != nil {
+					return
+				}
+			}
+		}
+	}}
+
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+func sameOrigin(config *websocket.Config, req *http.Request) (err error) {
+	config.Origin, err = websocket.Origin(config, req)
+	if err != nil || config.Origin == nil || config.Origin.Host == req.Host {
+
+		return err
+	}
+
+	return errors.New("origin does not match host")
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"auditor/exports"
+	"auditor/meta"
+	"auditor/model"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type exportsRoutes struct {
+	model       *model.Model
+	localPolicy *meta.LocalPolicy
+}
+
+func registerExportsRoutes(context string, api *Api) {
+	toReturn := exportsRoutes{
+		model:       api.model,
+		localPolicy: api.localPolicy,
+	}
+
+	exportsGroup := api.engine.Group(context)
+	exportsGroup.GET("/blocklist", toReturn.blocklist)
+}
+
+func (e *exportsRoutes) blocklist(c *gin.Context) {
+	format, formatErr := exports.FormatFrom(c.DefaultQuery("format", exports.HostsFormat))
+	if formatErr != nil {
+		c.String(http.StatusBadRequest, formatErr.Error())
+		return
+	}
+
+	selection, selectionErr := selectionFrom(c)
+	if selectionErr != nil {
+		c.String(http.StatusBadRequest, selectionErr.Error())
+		return
+	}
+
+	blocklist, blocklistErr := exports.Build(e.model, e.localPolicy, selection)
+	if blocklistErr != nil {
+		panic(blocklistErr)
+	}
+
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", exports.Render(format, blocklist))
+}
+
+func selectionFrom(c *gin.Context) (*exports.Selection, error) {
+	toReturn := &exports.Selection{
+		Labels:     listQuery(c, "label"),
+		Categories: listQuery(c, "category"),
+		AlertRules: listQuery(c, "alertRule"),
+	}
+
+	trackers, err := boolQuery(c, "trackers")
+	if err != nil {
+		return nil, err
+	}
+	toReturn.Trackers = trackers != nil && *trackers
+
+	if minSeverity, ok := c.GetQuery("minSeverity"); ok {
+		severity, err := model.SeverityFrom(minSeverity)
+		if err != nil {
+			return nil, err
+		}
+		toReturn.MinSeverity = &severity
+	}
+
+	if alertsSince, ok := c.GetQuery("alertsSince"); ok {
+		since, err := time.ParseDuration(alertsSince)
+		if err != nil || since <= 0 {
+			return nil, fmt.Errorf("alertsSince must be a positive duration")
+		}
+		toReturn.AlertsSince = since
+	}
+
+	return toReturn, toReturn.Validate()
+}
+
+// listQuery accepts both repeated and comma separated values.
+func listQuery(c *gin.Context, name string) []string {
+	toReturn := make([]string, 0)
+	for _, aValue := range c.QueryArray(name) {
+		for _, aPart := range strings.Split(aValue, ",") {
+			if aPart = strings.TrimSpace(aPart); aPart != "" {
+				toReturn = append(toReturn, aPart)
+			}
+		}
+	}
+
+	return toReturn
+}
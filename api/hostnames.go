@@ -0,0 +1,84 @@
+package api
+
+import (
+	"auditor/dga"
+	"auditor/model"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type hostnames struct {
+	model *model.Model
+}
+
+func registerHostnamesRoutes(context string, api *Api) {
+	toReturn := hostnames{
+		model: api.model,
+	}
+
+	hostnamesRoutes := api.engine.Group(context)
+	hostnamesRoutes.GET("", toReturn.allScores)
+	hostnamesRoutes.GET("/:hostname", toReturn.scoreByHostname)
+}
+
+func (h *hostnames) allScores(c *gin.Context) {
+	query, queryErr := hostnameScoresQueryFrom(c)
+	if queryErr != nil {
+		c.String(http.StatusBadRequest, queryErr.Error())
+		return
+	}
+
+	scores, scoresErr := h.model.ListHostnameScores(query)
+	if scoresErr != nil {
+		panic(scoresErr)
+	}
+
+	c.JSON(http.StatusOK, scores)
+}
+
+// scoreByHostname scores hostnames never seen too, scoring does not depend on
+// the traffic.
+func (h *hostnames) scoreByHostname(c *gin.Context) {
+	score, scoreErr := h.model.GetHostnameScore(dga.Normalize(c.Param("hostname")))
+	if errors.Is(scoreErr, model.HostnameScoreNotFoundErr) {
+		c.JSON(http.StatusOK, dga.Score(c.Param("hostname")))
+		return
+	}
+	if scoreErr != nil {
+		panic(scoreErr)
+	}
+
+	c.JSON(http.StatusOK, score)
+}
+
+func hostnameScoresQueryFrom(c *gin.Context) (*model.HostnameScoresQuery, error) {
+	toReturn := &model.HostnameScoresQuery{}
+
+	if limit, ok := c.GetQuery("limit"); ok {
+		parsedLimit, err := strconv.Atoi(limit)
+		if err != nil || parsedLimit <= 0 {
+			return nil, fmt.Errorf("limit must be a positive number")
+		}
+		toReturn.Limit = parsedLimit
+	}
+
+	if minScore, ok := c.GetQuery("minScore"); ok {
+		parsedMinScore, err := strconv.ParseFloat(minScore, 64)
+		if err != nil || parsedMinScore < 0 || parsedMinScore > 1 {
+			return nil, fmt.Errorf("minScore must be a number between 0 and 1")
+		}
+		toReturn.MinScore = parsedMinScore
+	}
+
+	suspicious, err := boolQuery(c, "suspicious")
+	if err != nil {
+		return nil, err
+	}
+	toReturn.Suspicious = suspicious
+
+	return toReturn, nil
+}
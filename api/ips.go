@@ -3,11 +3,18 @@ package api
 import (
 	"auditor/model"
 	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+const defaultNewDestinationsSince = 7 * 24 * time.Hour
+
 type ips struct {
 	model *model.Model
 }
@@ -20,10 +27,43 @@ func registerIpsRoutes(context string, api *Api) {
 	ipRoutes := api.engine.Group(context)
 	ipRoutes.GET("/", toReturn.allIps)
 	ipRoutes.GET("/:ip", toReturn.metaByIp)
+	ipRoutes.GET("/:ip/labels", toReturn.labelsByIp)
+	ipRoutes.PUT("/:ip/labels", toReturn.setLabels)
+	ipRoutes.DELETE("/:ip/labels", toReturn.deleteLabels)
+	ipRoutes.GET("/:ip/new-destinations", toReturn.newDestinationsByIp)
+}
+
+type metaView struct {
+	*model.Meta
+	Labels *model.Labels `json:"labels,omitempty"`
 }
 
 func (i *ips) allIps(c *gin.Context) {
-	ips, ipsErr := i.model.Get()
+	query, queryErr := ipsQueryFrom(c)
+	if queryErr != nil {
+		c.String(http.StatusBadRequest, queryErr.Error())
+		return
+	}
+
+	// dashboards written before the pagination expect every ip in an array
+	_, hasLimit := c.GetQuery("limit")
+	_, hasCursor := c.GetQuery("cursor")
+	if !hasLimit && !hasCursor {
+		all, allErr := i.model.ListAllIps(query)
+		if allErr != nil {
+			panic(allErr)
+		}
+
+		c.JSON(http.StatusOK, all)
+		return
+	}
+
+	ips, ipsErr := i.model.ListIps(query)
+	if errors.Is(ipsErr, model.InvalidCursorErr) {
+		c.String(http.StatusBadRequest, ipsErr.Error())
+		return
+	}
+
 	if ipsErr != nil {
 		panic(ipsErr)
 	}
@@ -31,19 +71,168 @@ func (i *ips) allIps(c *gin.Context) {
 	c.JSON(http.StatusOK, ips)
 }
 
+func ipsQueryFrom(c *gin.Context) (*model.IpsQuery, error) {
+	toReturn := &model.IpsQuery{
+		Cursor: c.Query("cursor"),
+	}
+
+	if limit, ok := c.GetQuery("limit"); ok {
+		parsedLimit, err := strconv.Atoi(limit)
+		if err != nil || parsedLimit <= 0 {
+			return nil, fmt.Errorf("limit must be a positive number")
+		}
+		toReturn.Limit = parsedLimit
+	}
+
+	switch c.DefaultQuery("sort", "ip") {
+	case "ip":
+		toReturn.SortBy = model.SortByIp
+	case "firstSeen":
+		toReturn.SortBy = model.SortByFirstSeen
+	case "lastSeen":
+		toReturn.SortBy = model.SortByLastSeen
+	default:
+		return nil, fmt.Errorf("sort must be one of ip, firstSeen, lastSeen")
+	}
+
+	switch c.DefaultQuery("order", "asc") {
+	case "asc":
+		toReturn.Descending = false
+	case "desc":
+		toReturn.Descending = true
+	default:
+		return nil, fmt.Errorf("order must be one of asc, desc")
+	}
+
+	if cidr, ok := c.GetQuery("cidr"); ok {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		toReturn.Filter.Cidr = network
+	}
+
+	if country, ok := c.GetQuery("country"); ok {
+		toReturn.Filter.Country = &country
+	}
+
+	isCdn, err := boolQuery(c, "isCdn")
+	if err != nil {
+		return nil, err
+	}
+	toReturn.Filter.IsCdn = isCdn
+
+	hasVulnerabilities, err := boolQuery(c, "hasVulnerabilities")
+	if err != nil {
+		return nil, err
+	}
+	toReturn.Filter.HasVulnerabilities = hasVulnerabilities
+
+	if category, ok := c.GetQuery("category"); ok {
+		if !isCategory(category) {
+			return nil, fmt.Errorf("category must be one of %s", strings.Join(model.Categories, ", "))
+		}
+		toReturn.Filter.Category = &category
+	}
+
+	toReturn.Filter.Label, toReturn.Filter.Owner = labelsFilterFrom(c)
+
+	return toReturn, nil
+}
+
+func isCategory(value string) bool {
+	for _, aCategory := range model.Categories {
+		if strings.EqualFold(aCategory, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func boolQuery(c *gin.Context, name string) (*bool, error) {
+	value, ok := c.GetQuery(name)
+	if !ok {
+		return nil, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a boolean", name)
+	}
+
+	return &parsed, nil
+}
+
 func (i *ips) metaByIp(c *gin.Context) {
 	ip := c.Param("ip")
 	meta, metaErr := i.model.GetMeta(ip)
+	if metaErr != nil && !errors.Is(metaErr, model.IpNotFoundErr) {
+
+		panic(metaErr)
+	}
 
-	if errors.Is(metaErr, model.IpNotFoundErr) {
+	labels, labelsErr := i.model.ResolveLabels(ip)
+	if labelsErr != nil && !errors.Is(labelsErr, model.LabelsNotFoundErr) {
+
+		panic(labelsErr)
+	}
+
+	if meta == nil && labels == nil {
 		c.Status(http.StatusNotFound)
 		return
 	}
 
-	if metaErr != nil {
+	if meta == nil {
+		meta = &model.Meta{}
+	}
+
+	c.JSON(http.StatusOK, &metaView{
+		Meta:   meta,
+		Labels: labels,
+	})
+}
+
+func (i *ips) labelsByIp(c *gin.Context) {
+	labels, labelsErr := i.model.GetIpLabels(c.Param("ip"))
+	respondLabels(c, labels, labelsErr)
+}
+
+func (i *ips) setLabels(c *gin.Context) {
+	ip := net.ParseIP(c.Param("ip"))
+	if ip == nil {
+		c.String(http.StatusBadRequest, "%s is not an ip", c.Param("ip"))
+		return
+	}
+
+	labels, labelsErr := labelsFromBody(c)
+	if labelsErr != nil {
+		c.String(http.StatusBadRequest, labelsErr.Error())
+		return
+	}
 
-		panic(metaErr)
+	respondLabels(c, labels, i.model.SetIpLabels(ip.String(), labels))
+}
+
+func (i *ips) deleteLabels(c *gin.Context) {
+	respondLabels(c, nil, i.model.DeleteIpLabels(c.Param("ip")))
+}
+
+func (i *ips) newDestinationsByIp(c *gin.Context) {
+	since := time.Now().Add(-defaultNewDestinationsSince)
+	if value, ok := c.GetQuery("since"); ok {
+		parsedSince, err := sinceFrom(value)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		since = parsedSince
+	}
+
+	newDestinations, newDestinationsErr := i.model.ListNewDestinations(c.Param("ip"), since)
+	if newDestinationsErr != nil {
+		panic(newDestinationsErr)
 	}
 
-	c.JSON(http.StatusOK, meta)
+	c.JSON(http.StatusOK, newDestinations)
 }
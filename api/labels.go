@@ -0,0 +1,53 @@
+package api
+
+import (
+	"auditor/model"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func labelsFromBody(c *gin.Context) (*model.Labels, error) {
+	labels := &model.Labels{}
+	if err := c.ShouldBindJSON(labels); err != nil {
+		return nil, err
+	}
+
+	if labels.Name == "" && labels.Owner == "" && labels.Notes == "" && len(labels.Tags) == 0 {
+		return nil, errors.New("labels need at least one of name, owner, notes and tags")
+	}
+
+	return labels, nil
+}
+
+func respondLabels(c *gin.Context, labels *model.Labels, err error) {
+	if errors.Is(err, model.LabelsNotFoundErr) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		panic(err)
+	}
+
+	if labels == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	c.JSON(http.StatusOK, labels)
+}
+
+func labelsFilterFrom(c *gin.Context) (*string, *string) {
+	var label, owner *string
+	if value, ok := c.GetQuery("label"); ok {
+		label = &value
+	}
+
+	if value, ok := c.GetQuery("owner"); ok {
+		owner = &value
+	}
+
+	return label, owner
+}
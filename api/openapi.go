@@ -0,0 +1,17 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed openapi.json
+var openApiDocument []byte
+
+func registerOpenApiRoutes(path string, api *Api) {
+	api.engine.GET(path, func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", openApiDocument)
+	})
+}
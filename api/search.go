@@ -0,0 +1,47 @@
+package api
+
+import (
+	"auditor/model"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type search struct {
+	model *model.Model
+}
+
+func registerSearchRoutes(context string, api *Api) {
+	toReturn := search{
+		model: api.model,
+	}
+
+	searchRoutes := api.engine.Group(context)
+	searchRoutes.GET("", toReturn.search)
+}
+
+func (s *search) search(c *gin.Context) {
+	term, ok := c.GetQuery("q")
+	if !ok {
+		c.String(http.StatusBadRequest, "q is mandatory")
+		return
+	}
+
+	limit := model.DefaultSearchLimit
+	if limitParam, ok := c.GetQuery("limit"); ok {
+		parsedLimit, err := strconv.Atoi(limitParam)
+		if err != nil || parsedLimit <= 0 {
+			c.String(http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+		limit = parsedLimit
+	}
+
+	results, searchErr := s.model.Search(term, limit)
+	if searchErr != nil {
+		panic(searchErr)
+	}
+
+	c.JSON(http.StatusOK, results)
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"auditor/model"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type trackers struct {
+	model *model.Model
+}
+
+func registerTrackersRoutes(context string, api *Api) {
+	toReturn := trackers{
+		model: api.model,
+	}
+
+	trackersRoutes := api.engine.Group(context)
+	trackersRoutes.GET("", toReturn.summary)
+}
+
+func (t *trackers) summary(c *gin.Context) {
+	limit, limitErr := trackersLimitFrom(c)
+	if limitErr != nil {
+		c.String(http.StatusBadRequest, limitErr.Error())
+		return
+	}
+
+	summary, summaryErr := t.model.SummarizeTrackers(limit)
+	if summaryErr != nil {
+		panic(summaryErr)
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+func trackersLimitFrom(c *gin.Context) (int, error) {
+	limit, ok := c.GetQuery("limit")
+	if !ok {
+		return model.DefaultTopTrackers, nil
+	}
+
+	parsedLimit, err := strconv.Atoi(limit)
+	if err != nil || parsedLimit <= 0 {
+		return 0, fmt.Errorf("limit must be a positive number")
+	}
+
+	return parsedLimit, nil
+}
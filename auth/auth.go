@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type Role uint8
+
+const (
+	ReadOnly Role = iota
+	Admin
+)
+
+func RoleFrom(value string) (Role, error) {
+	switch strings.ToLower(value) {
+	case "read-only", "readonly", "read":
+		return ReadOnly, nil
+	case "admin":
+		return Admin, nil
+	}
+
+	return ReadOnly, fmt.Errorf("role %s does not exist", value)
+}
+
+func (r Role) String() string {
+	if r == Admin {
+		return "admin"
+	}
+
+	return "read-only"
+}
+
+type Identity struct {
+	Name string
+	Role Role
+}
+
+var InvalidCredentialsErr = errors.New("invalid credentials")
+
+// Authenticator returns a nil identity, without errors, when the request does
+// not carry the kind of credentials it handles, so that the next one is tried.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+type tokens struct {
+	identities map[string]*Identity
+}
+
+// NewTokens reads bearer tokens from a file with a "<token> <role> [name]"
+// entry per line.
+func NewTokens(file string) (Authenticator, error) {
+	toReturn := &tokens{
+		identities: make(map[string]*Identity),
+	}
+
+	err := readEntries(file, func(fields []string) error {
+		if len(fields) < 2 {
+			return errors.New("token entries are <token> <role> [name]")
+		}
+
+		role, err := RoleFrom(fields[1])
+		if err != nil {
+			return err
+		}
+
+		name := fmt.Sprintf("token-%d", len(toReturn.identities))
+		if len(fields) > 2 {
+			name = fields[2]
+		}
+
+		toReturn.identities[fields[0]] = &Identity{
+			Name: name,
+			Role: role,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func (t *tokens) Authenticate(r *http.Request) (*Identity, error) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return nil, nil
+	}
+
+	presented := []byte(strings.TrimPrefix(header, "Bearer "))
+	for token, identity := range t.identities {
+		if subtle.ConstantTimeCompare([]byte(token), presented) == 1 {
+			return identity, nil
+		}
+	}
+
+	return nil, InvalidCredentialsErr
+}
+
+type basicUser struct {
+	hash     []byte
+	identity *Identity
+}
+
+type basic struct {
+	users map[string]*basicUser
+}
+
+// NewBasic reads users from a file with a "<user>:<bcrypt hash>:<role>" entry
+// per line, hashes can be generated with htpasswd -nbB.
+func NewBasic(file string) (Authenticator, error) {
+	toReturn := &basic{
+		users: make(map[string]*basicUser),
+	}
+
+	err := readEntries(file, func(fields []string) error {
+		parts := strings.Split(strings.Join(fields, ""), ":")
+		if len(parts) != 3 {
+			return errors.New("basic entries are <user>:<bcrypt hash>:<role>")
+		}
+
+		if _, err := bcrypt.Cost([]byte(parts[1])); err != nil {
+			return fmt.Errorf("user %s: %w", parts[0], err)
+		}
+
+		role, err := RoleFrom(parts[2])
+		if err != nil {
+			return err
+		}
+
+		toReturn.users[parts[0]] = &basicUser{
+			hash: []byte(parts[1]),
+			identity: &Identity{
+				Name: parts[0],
+				Role: role,
+			},
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func (b *basic) Authenticate(r *http.Request) (*Identity, error) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, nil
+	}
+
+	user, ok := b.users[username]
+	if !ok || bcrypt.CompareHashAndPassword(user.hash, []byte(password)) != nil {
+		return nil, InvalidCredentialsErr
+	}
+
+	return user.identity, nil
+}
+
+type clientCertificates struct {
+	roles map[string]Role
+}
+
+// NewClientCertificates maps the common name of verified client certificates
+// to roles, reading a "<common name> <role>" entry per line.
+func NewClientCertificates(file string) (Authenticator, error) {
+	toReturn := &clientCertificates{
+		roles: make(map[string]Role),
+	}
+
+	err := readEntries(file, func(fields []string) error {
+		if len(fields) < 2 {
+			return errors.New("client certificate entries are <common name> <role>")
+		}
+
+		role, err := RoleFrom(fields[len(fields)-1])
+		if err != nil {
+			return err
+		}
+
+		toReturn.roles[strings.Join(fields[:len(fields)-1], " ")] = role
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func (c *clientCertificates) Authenticate(r *http.Request) (*Identity, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, nil
+	}
+
+	commonName := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	role, ok := c.roles[commonName]
+	if !ok {
+		return nil, InvalidCredentialsErr
+	}
+
+	return &Identity{
+		Name: commonName,
+		Role: role,
+	}, nil
+}
+
+func readEntries(file string, onEntry func(fields []string) error) error {
+	reader, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	scanner := bufio.NewScanner(reader)
+	line := 0
+	for scanner.Scan() {
+		line++
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		if err := onEntry(strings.Fields(entry)); err != nil {
+			return fmt.Errorf("%s:%d: %w", file, line, err)
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,422 @@
+package client
+
+import (
+	"auditor/exports"
+	"auditor/model"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type UnexpectedStatusErr struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *UnexpectedStatusErr) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+type Client struct {
+	baseUrl    *url.URL
+	httpClient *http.Client
+}
+
+func New(baseUrl string, httpClient *http.Client) (*Client, error) {
+	parsedUrl, err := url.Parse(strings.TrimSuffix(baseUrl, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Client{
+		baseUrl:    parsedUrl,
+		httpClient: httpClient,
+	}, nil
+}
+
+func (c *Client) ListIps(ctx context.Context, query *model.IpsQuery) (*model.IpsPage, error) {
+	// without a limit the whole listing is returned as an array
+	values := url.Values{}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = model.DefaultIpsLimit
+	}
+	values.Set("limit", strconv.Itoa(limit))
+	if query.Cursor != "" {
+		values.Set("cursor", query.Cursor)
+	}
+
+	switch query.SortBy {
+	case model.SortByFirstSeen:
+		values.Set("sort", "firstSeen")
+	case model.SortByLastSeen:
+		values.Set("sort", "lastSeen")
+	}
+	if query.Descending {
+		values.Set("order", "desc")
+	}
+
+	if query.Filter.Cidr != nil {
+		values.Set("cidr", query.Filter.Cidr.String())
+	}
+	if query.Filter.Country != nil {
+		values.Set("country", *query.Filter.Country)
+	}
+	if query.Filter.IsCdn != nil {
+		values.Set("isCdn", strconv.FormatBool(*query.Filter.IsCdn))
+	}
+	if query.Filter.HasVulnerabilities != nil {
+		values.Set("hasVulnerabilities", strconv.FormatBool(*query.Filter.HasVulnerabilities))
+	}
+	if query.Filter.Category != nil {
+		values.Set("category", *query.Filter.Category)
+	}
+	setLabelsFilter(values, query.Filter.Label, query.Filter.Owner)
+
+	toReturn := &model.IpsPage{}
+	if err := c.get(ctx, "/ip/", values, toReturn, nil); err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func (c *Client) GetMeta(ctx context.Context, ip string) (*model.Meta, error) {
+	toReturn := &model.Meta{}
+	if err := c.get(ctx, "/ip/"+url.PathEscape(ip), nil, toReturn, model.IpNotFoundErr); err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func (c *Client) GetActions(ctx context.Context, ip string) (map[string][]string, error) {
+	toReturn := make(map[string][]string)
+	if err := c.get(ctx, "/actions/"+url.PathEscape(ip), nil, &toReturn, model.ActionNotFoundErr); err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func (c *Client) GetActionExporters(ctx context.Context, ip string) (map[string][]string, error) {
+	toReturn := make(map[string][]string)
+	if err := c.get(ctx, "/actions/"+url.PathEscape(ip)+"/exporters", nil, &toReturn, model.ActionNotFoundErr); err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func (c *Client) ListDevices(ctx context.Context, filter *model.DevicesFilter) ([]*model.Device, error) {
+	values := url.Values{}
+	if filter != nil {
+		setLabelsFilter(values, filter.Label, filter.Owner)
+	}
+
+	toReturn := make([]*model.Device, 0)
+	if err := c.get(ctx, "/devices", values, &toReturn, nil); err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func (c *Client) GetDeviceActions(ctx context.Context, mac string) (map[string][]string, error) {
+	toReturn := make(map[string][]string)
+	if err := c.get(ctx, "/devices/"+url.PathEscape(mac)+"/actions", nil, &toReturn, model.DeviceNotFoundErr); err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func (c *Client) ListDeviceCategories(ctx context.Context, mac string, since time.Time, bucket time.Duration) ([]*model.CategoryBucket, error) {
+	values := url.Values{}
+	if !since.IsZero() {
+		values.Set("since", since.Format(time.RFC3339))
+	}
+	if bucket > 0 {
+		values.Set("bucket", bucket.String())
+	}
+
+	toReturn := make([]*model.CategoryBucket, 0)
+	if err := c.get(ctx, "/devices/"+url.PathEscape(mac)+"/categories", values, &toReturn, model.DeviceNotFoundErr); err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func (c *Client) GetDeviceTrackers(ctx context.Context, mac string, limit int) (*model.DeviceTrackers, error) {
+	values := url.Values{}
+	if limit > 0 {
+		values.Set("limit", strconv.Itoa(limit))
+	}
+
+	toReturn := &model.DeviceTrackers{}
+	if err := c.get(ctx, "/devices/"+url.PathEscape(mac)+"/trackers", values, toReturn, model.DeviceNotFoundErr); err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func (c *Client) SummarizeTrackers(ctx context.Context, limit int) (*model.TrackersSummary, error) {
+	values := url.Values{}
+	if limit > 0 {
+		values.Set("limit", strconv.Itoa(limit))
+	}
+
+	toReturn := &model.TrackersSummary{}
+	if err := c.get(ctx, "/trackers", values, toReturn, nil); err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func (c *Client) GetIpLabels(ctx context.Context, ip string) (*model.Labels, error) {
+	return c.getLabels(ctx, "/ip/"+url.PathEscape(ip)+"/labels")
+}
+
+func (c *Client) SetIpLabels(ctx context.Context, ip string, labels *model.Labels) error {
+	return c.setLabels(ctx, "/ip/"+url.PathEscape(ip)+"/labels", labels)
+}
+
+func (c *Client) DeleteIpLabels(ctx context.Context, ip string) error {
+	return c.do(ctx, http.MethodDelete, "/ip/"+url.PathEscape(ip)+"/labels", nil, nil, nil, model.LabelsNotFoundErr)
+}
+
+func (c *Client) ListNewDestinations(ctx context.Context, ip string, since time.Time) ([]*model.NewDestination, error) {
+	values := url.Values{}
+	if !since.IsZero() {
+		values.Set("since", since.Format(time.RFC3339))
+	}
+
+	toReturn := make([]*model.NewDestination, 0)
+	if err := c.get(ctx, "/ip/"+url.PathEscape(ip)+"/new-destinations", values, &toReturn, nil); err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func (c *Client) GetDeviceLabels(ctx context.Context, mac string) (*model.Labels, error) {
+	return c.getLabels(ctx, "/devices/"+url.PathEscape(mac)+"/labels")
+}
+
+func (c *Client) SetDeviceLabels(ctx context.Context, mac string, labels *model.Labels) error {
+	return c.setLabels(ctx, "/devices/"+url.PathEscape(mac)+"/labels", labels)
+}
+
+func (c *Client) DeleteDeviceLabels(ctx context.Context, mac string) error {
+	return c.do(ctx, http.MethodDelete, "/devices/"+url.PathEscape(mac)+"/labels", nil, nil, nil, model.LabelsNotFoundErr)
+}
+
+func (c *Client) getLabels(ctx context.Context, path string) (*model.Labels, error) {
+	toReturn := &model.Labels{}
+	if err := c.get(ctx, path, nil, toReturn, model.LabelsNotFoundErr); err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func (c *Client) setLabels(ctx context.Context, path string, labels *model.Labels) error {
+	body, err := json.Marshal(labels)
+	if err != nil {
+		return err
+	}
+
+	return c.do(ctx, http.MethodPut, path, nil, bytes.NewReader(body), nil, nil)
+}
+
+func setLabelsFilter(values url.Values, label *string, owner *string) {
+	if label != nil {
+		values.Set("label", *label)
+	}
+	if owner != nil {
+		values.Set("owner", *owner)
+	}
+}
+
+func (c *Client) ListAlerts(ctx context.Context, query *model.AlertsQuery) ([]*model.Alert, error) {
+	values := url.Values{}
+	if query.Limit > 0 {
+		values.Set("limit", strconv.Itoa(query.Limit))
+	}
+	if !query.Since.IsZero() {
+		values.Set("since", query.Since.Format(time.RFC3339))
+	}
+	if query.MinSeverity != model.Info {
+		values.Set("severity", query.MinSeverity.String())
+	}
+	if query.Rule != "" {
+		values.Set("rule", query.Rule)
+	}
+	if query.Source != "" {
+		values.Set("src", query.Source)
+	}
+
+	toReturn := make([]*model.Alert, 0)
+	if err := c.get(ctx, "/alerts", values, &toReturn, nil); err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func (c *Client) ListBeacons(ctx context.Context, query *model.BeaconsQuery) ([]*model.Beacon, error) {
+	values := url.Values{}
+	if query.Limit > 0 {
+		values.Set("limit", strconv.Itoa(query.Limit))
+	}
+	if query.MinScore > 0 {
+		values.Set("minScore", strconv.FormatFloat(query.MinScore, 'f', -1, 64))
+	}
+	if query.Source != "" {
+		values.Set("src", query.Source)
+	}
+
+	toReturn := make([]*model.Beacon, 0)
+	if err := c.get(ctx, "/analysis/beacons", values, &toReturn, nil); err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func (c *Client) ListHostnameScores(ctx context.Context, query *model.HostnameScoresQuery) ([]*model.HostnameScore, error) {
+	values := url.Values{}
+	if query.Limit > 0 {
+		values.Set("limit", strconv.Itoa(query.Limit))
+	}
+	if query.MinScore > 0 {
+		values.Set("minScore", strconv.FormatFloat(query.MinScore, 'f', -1, 64))
+	}
+	if query.Suspicious != nil {
+		values.Set("suspicious", strconv.FormatBool(*query.Suspicious))
+	}
+
+	toReturn := make([]*model.HostnameScore, 0)
+	if err := c.get(ctx, "/hostnames", values, &toReturn, nil); err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func (c *Client) GetHostnameScore(ctx context.Context, hostname string) (*model.HostnameScore, error) {
+	toReturn := &model.HostnameScore{}
+	if err := c.get(ctx, "/hostnames/"+url.PathEscape(hostname), nil, toReturn, nil); err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+// ExportBlocklist returns the blocklist of the selection rendered in the format
+// as served, ready to be written for the dns server or the firewall.
+func (c *Client) ExportBlocklist(ctx context.Context, format string, selection *exports.Selection) ([]byte, error) {
+	values := url.Values{}
+	values.Set("format", format)
+	for _, aLabel := range selection.Labels {
+		values.Add("label", aLabel)
+	}
+	for _, aCategory := range selection.Categories {
+		values.Add("category", aCategory)
+	}
+	if selection.Trackers {
+		values.Set("trackers", "true")
+	}
+	for _, aRule := range selection.AlertRules {
+		values.Add("alertRule", aRule)
+	}
+	if selection.MinSeverity != nil {
+		values.Set("minSeverity", selection.MinSeverity.String())
+	}
+	if selection.AlertsSince > 0 {
+		values.Set("alertsSince", selection.AlertsSince.String())
+	}
+
+	var toReturn []byte
+	if err := c.get(ctx, "/exports/blocklist", values, &toReturn, nil); err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func (c *Client) Search(ctx context.Context, term string, limit int) ([]*model.SearchResult, error) {
+	values := url.Values{}
+	values.Set("q", term)
+	if limit > 0 {
+		values.Set("limit", strconv.Itoa(limit))
+	}
+
+	toReturn := make([]*model.SearchResult, 0)
+	if err := c.get(ctx, "/search", values, &toReturn, nil); err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func (c *Client) get(ctx context.Context, path string, values url.Values, into interface{}, notFoundErr error) error {
+	return c.do(ctx, http.MethodGet, path, values, nil, into, notFoundErr)
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, values url.Values, body io.Reader, into interface{}, notFoundErr error) error {
+	requestUrl := *c.baseUrl
+	requestUrl.Path = c.baseUrl.Path + path
+	requestUrl.RawQuery = values.Encode()
+
+	request, err := http.NewRequestWithContext(ctx, method, requestUrl.String(), body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound && notFoundErr != nil {
+		return notFoundErr
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+		return &UnexpectedStatusErr{
+			StatusCode: response.StatusCode,
+			Body:       string(responseBody),
+		}
+	}
+
+	if into == nil || response.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	if raw, ok := into.(*[]byte); ok {
+		*raw, err = io.ReadAll(response.Body)
+		return err
+	}
+
+	return json.NewDecoder(response.Body).Decode(into)
+}
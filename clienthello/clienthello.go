@@ -0,0 +1,196 @@
+package clienthello
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/yarochewsky/tlsx"
+)
+
+var (
+	NotClientHelloErr = errors.New("packet is not a tls client hello")
+	NoServerNameErr   = errors.New("client hello does not carry a server name")
+)
+
+const (
+	recordHeaderLen     = 5
+	handshakeHeaderLen  = 4
+	handshakeRecordType = 22
+	clientHelloType     = 1
+	serverNameExtension = 0
+	hostNameType        = 0
+)
+
+type ClientHello struct {
+	SrcAddr  string
+	DstAddr  string
+	SrcPort  uint16
+	DstPort  uint16
+	Hostname string
+}
+
+func FromPacket(packet gopacket.Packet) (*ClientHello, error) {
+	if packet.NetworkLayer() == nil || packet.TransportLayer() == nil {
+
+		return nil, NotClientHelloErr
+	}
+
+	tcp, ok := packet.Layer(layers.LayerTypeTCP).(*layers.TCP)
+	if !ok || len(tcp.LayerPayload()) == 0 {
+
+		return nil, NotClientHelloErr
+	}
+
+	hostname, err := ServerName(tcp.LayerPayload())
+	if err != nil {
+
+		return nil, err
+	}
+
+	srcPort, err := strconv.ParseUint(packet.TransportLayer().TransportFlow().Src().String(), 10, 16)
+	if err != nil {
+
+		return nil, err
+	}
+
+	dstPort, err := strconv.ParseUint(packet.TransportLayer().TransportFlow().Dst().String(), 10, 16)
+	if err != nil {
+
+		return nil, err
+	}
+
+	return &ClientHello{
+		SrcAddr:  packet.NetworkLayer().NetworkFlow().Src().String(),
+		DstAddr:  packet.NetworkLayer().NetworkFlow().Dst().String(),
+		SrcPort:  uint16(srcPort),
+		DstPort:  uint16(dstPort),
+		Hostname: hostname,
+	}, nil
+}
+
+// ServerName reads the SNI from a tls record. Records cut short, like the
+// sampled headers exported by sFlow agents, are walked as far as they go: the
+// name is returned as long as its extension made it into the capture.
+func ServerName(payload []byte) (string, error) {
+	if len(payload) < recordHeaderLen || payload[0] != handshakeRecordType {
+
+		return "", NotClientHelloErr
+	}
+
+	recordLen := int(binary.BigEndian.Uint16(payload[3:5]))
+	if len(payload) < recordHeaderLen+recordLen {
+
+		return truncatedServerName(payload)
+	}
+
+	hello := tlsx.ClientHello{}
+	if err := hello.Unmarshal(payload); err != nil {
+
+		return "", NotClientHelloErr
+	}
+
+	if hello.SNI == "" {
+
+		return "", NoServerNameErr
+	}
+
+	return hello.SNI, nil
+}
+
+func truncatedServerName(payload []byte) (string, error) {
+	if len(payload) < recordHeaderLen+handshakeHeaderLen ||
+		payload[0] != handshakeRecordType ||
+		payload[recordHeaderLen] != clientHelloType {
+
+		return "", NotClientHelloErr
+	}
+
+	// version and random
+	data := payload[recordHeaderLen+handshakeHeaderLen:]
+	data, ok := skip(data, 2+32)
+	if !ok {
+		return "", NoServerNameErr
+	}
+
+	// session id, cipher suites and compression methods
+	for _, prefixLen := range []int{1, 2, 1} {
+		data, ok = skipPrefixed(data, prefixLen)
+		if !ok {
+			return "", NoServerNameErr
+		}
+	}
+
+	// extensions length: the list itself may be truncated
+	data, ok = skip(data, 2)
+	if !ok {
+		return "", NoServerNameErr
+	}
+
+	for len(data) >= 4 {
+		extensionType := binary.BigEndian.Uint16(data[0:2])
+		extensionLen := int(binary.BigEndian.Uint16(data[2:4]))
+		data = data[4:]
+
+		if extensionType == serverNameExtension {
+			if len(data) < extensionLen {
+				return "", NoServerNameErr
+			}
+
+			return serverNameFromExtension(data[:extensionLen])
+		}
+
+		data, ok = skip(data, extensionLen)
+		if !ok {
+			return "", NoServerNameErr
+		}
+	}
+
+	return "", NoServerNameErr
+}
+
+func serverNameFromExtension(data []byte) (string, error) {
+	data, ok := skip(data, 2)
+	if !ok {
+		return "", NoServerNameErr
+	}
+
+	for len(data) >= 3 {
+		nameType := data[0]
+		nameLen := int(binary.BigEndian.Uint16(data[1:3]))
+		data = data[3:]
+		if len(data) < nameLen {
+			return "", NoServerNameErr
+		}
+
+		if nameType == hostNameType && nameLen > 0 {
+			return string(data[:nameLen]), nil
+		}
+		data = data[nameLen:]
+	}
+
+	return "", NoServerNameErr
+}
+
+func skip(data []byte, n int) ([]byte, bool) {
+	if len(data) < n {
+		return nil, false
+	}
+
+	return data[n:], true
+}
+
+func skipPrefixed(data []byte, prefixLen int) ([]byte, bool) {
+	if len(data) < prefixLen {
+		return nil, false
+	}
+
+	length := 0
+	for _, b := range data[:prefixLen] {
+		length = length<<8 | int(b)
+	}
+
+	return skip(data[prefixLen:], length)
+}
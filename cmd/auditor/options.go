@@ -4,11 +4,13 @@ import (
 	"auditor/handling"
 	"auditor/options"
 	"flag"
+	"fmt"
 	"net"
 	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var (
@@ -19,7 +21,7 @@ var (
 	ipExclusion                       = flag.String("ip-exclusion", "", "Comma separated ips to exclude from the network")
 
 	listenAddrEnv, listenAddrEnvSet = os.LookupEnv("NFLOW_LISTEN_ADDR")
-	listenAddr                      = flag.String("listen-addr", "netflow://:2055", "Address and port to listen on")
+	listenAddr                      = flag.String("listen-addr", "netflow://:2055", "Address and port to listen on. Scheme selects the collector: netflow (v9 and IPFIX), sflow or nfl (v5)")
 
 	formatEnv, formatEnvSet = os.LookupEnv("NFLOW_FORMAT")
 	format                  = flag.String("format", "format", "Formatter to use: take a look at https://github.com/netsampler/goflow2/tree/main/format")
@@ -29,6 +31,12 @@ var (
 
 	workersEnv, workersEnvSet = os.LookupEnv("NFLOW_WORKERS")
 	workers                   = flag.Int("workers", 1, "Number of nflow ingestion workers")
+
+	dedupWindowEnv, dedupWindowEnvSet = os.LookupEnv("NFLOW_DEDUP_WINDOW")
+	dedupWindow                       = flag.Duration("dedup-window", time.Minute, "Time window in which the same flow reported by different exporters is counted once. Zero disables deduplication")
+
+	exporterPriorityEnv, exporterPriorityEnvSet = os.LookupEnv("NFLOW_EXPORTER_PRIORITY")
+	exporterPriority                            = flag.String("exporter-priority", "", "Comma separated exporter addresses, most trusted first, used to pick the observation point of duplicated flows. Flows of the other exporters are held for the dedup window")
 )
 
 type Options struct {
@@ -74,6 +82,12 @@ func parseOptions() (*Options, error) {
 		return nil, err
 	}
 
+	scheme := strings.ToLower(listenAddrUrl.Scheme)
+	if !handling.IsSupportedScheme(scheme) {
+
+		return nil, fmt.Errorf("listen address scheme %q is not supported", listenAddrUrl.Scheme)
+	}
+
 	hostname := listenAddrUrl.Hostname()
 	port, err := strconv.ParseUint(listenAddrUrl.Port(), 10, 64)
 	if err != nil {
@@ -98,15 +112,40 @@ func parseOptions() (*Options, error) {
 		*workers = int(workersFromEnv)
 	}
 
+	if dedupWindowEnvSet {
+		dedupWindowFromEnv, err := time.ParseDuration(dedupWindowEnv)
+		if err != nil {
+			return nil, err
+		}
+
+		*dedupWindow = dedupWindowFromEnv
+	}
+
+	if exporterPriorityEnvSet {
+		exporterPriority = &exporterPriorityEnv
+	}
+
+	var exporters []string
+	for _, exporter := range strings.Split(*exporterPriority, ",") {
+		exporterIp := net.ParseIP(strings.TrimSpace(exporter))
+		if exporterIp != nil {
+			exporters = append(exporters, exporterIp.String())
+		}
+	}
+
 	nFlowConf := &handling.NflowConfiguration{
 		Format:    format,
 		Transport: transport,
 
 		Workers:    workers,
+		Scheme:     &scheme,
 		Hostname:   &hostname,
 		Port:       &port,
 		Cidr:       cidrToConsider,
 		Exclusions: ipsToExclude,
+
+		DedupWindow:      dedupWindow,
+		ExporterPriority: exporters,
 	}
 
 	opts := Options{
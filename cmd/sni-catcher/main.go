@@ -7,10 +7,17 @@ import (
 
 	_ "github.com/breml/rootcerts"
 
+	"auditor/alerts"
+	"auditor/analysis"
 	"auditor/api"
+	"auditor/dhcp"
+	"auditor/events"
+	"auditor/exports"
 	"auditor/healthiness"
-	"auditor/meta"
+	"auditor/intel"
+	metaFacility "auditor/meta"
 	"auditor/model"
+	"auditor/notify"
 	"auditor/sni"
 )
 
@@ -29,7 +36,30 @@ func main() {
 		options.Logger.Log.Fatal(modelErr)
 	}
 
-	meta, metaErr := meta.New(options.Logger, model, options.Meta)
+	events := events.New(options.Logger)
+
+	notifier, notifierErr := notify.New(options.Logger, model, options.Notify)
+	if notifierErr != nil {
+		options.Logger.Log.Fatal(notifierErr)
+	}
+
+	alerts, alertsErr := alerts.New(options.Logger, model, notifier, options.Alerts)
+	if alertsErr != nil {
+		options.Logger.Log.Fatal(alertsErr)
+	}
+
+	threatIntel, threatIntelErr := intel.New(options.Logger, model, options.Intel)
+	if threatIntelErr != nil {
+		options.Logger.Log.Fatal(threatIntelErr)
+	}
+
+	localPolicy := metaFacility.NewLocalPolicy(options.Meta.LocalCidrs, options.Meta.LocalSuffixes)
+	exporter, exporterErr := exports.New(options.Logger, model, localPolicy, options.Exports)
+	if exporterErr != nil {
+		options.Logger.Log.Fatal(exporterErr)
+	}
+
+	meta, metaErr := metaFacility.New(options.Logger, model, events, alerts, threatIntel, options.Meta)
 	if metaErr != nil {
 		options.Logger.Log.Fatal(metaErr)
 	}
@@ -39,23 +69,67 @@ func main() {
 		options.Logger.Log.Fatal(sniErr)
 	}
 
-	api, apiErr := api.New(options.Logger, model)
+	api, apiErr := api.New(options.Logger, model, events, localPolicy, options.Api)
 	if apiErr != nil {
 		options.Logger.Log.Fatal(apiErr)
 	}
 
 	go sniHandler.Handle()
+	go model.FromSightings(sniHandler.Sightings)
+	go alerts.FromNewDestinations(model.NewDestinations)
+	leases := dhcp.New(options.Logger, model, options.Dhcp)
+	go leases.Run()
+	go notifier.Run()
+	go threatIntel.Run()
+	go exporter.Run()
+	analyzer := analysis.New(options.Logger, model, alerts, options.Analysis)
+	go analyzer.Run()
 	go meta.FromChan(sniHandler.C)
-	go api.Up()
+	go func() {
+		if err := api.Up(); err != nil {
+			options.Logger.Log.Fatal(err)
+		}
+	}()
+
+	health := healthiness.New(options.Logger, options.Health)
+	health.Register("model", healthiness.Liveness, model.Health)
+	health.Register("capture", healthiness.Liveness, sniHandler.Heartbeat.Running)
+	health.Register("traffic", healthiness.Readiness, sniHandler.Heartbeat.Recent(health.MaxSilence()))
+	health.Register("enrichment", healthiness.Readiness, meta.QueueHealth)
+	health.Register("dns", healthiness.Readiness, meta.ProviderHealth(metaFacility.DnsProvider))
+	health.RegisterInformational("shodan", healthiness.Readiness, meta.ProviderHealth(metaFacility.ShodanProvider))
+	health.RegisterInformational("cdncheck", healthiness.Readiness, meta.ProviderHealth(metaFacility.CdncheckProvider))
 
-	go healthiness.Healthiness(options.Logger)
+	go func() {
+		if err := health.Serve(); err != nil {
+			options.Logger.Log.Fatal(err)
+		}
+	}()
 	sig := <-stop
 	options.Logger.Log.Infof("Caught %v", sig)
 
 	sniHandler.Close()
 	options.Logger.Log.Debug("Sni handler closed")
 
+	leases.Dispose()
+	options.Logger.Log.Debug("Leases importer disposed")
+
+	analyzer.Dispose()
+	options.Logger.Log.Debug("Analyzer disposed")
+
+	threatIntel.Dispose()
+	options.Logger.Log.Debug("Threat intelligence disposed")
+
+	exporter.Dispose()
+	options.Logger.Log.Debug("Exporter disposed")
+
 	meta.Dispose()
 	options.Logger.Log.Debug("Meta disposed")
+
+	notifier.Dispose()
+	options.Logger.Log.Debug("Notifier disposed")
+
+	events.Dispose()
+	options.Logger.Log.Debug("Events disposed")
 	os.Exit(0)
 }
@@ -12,7 +12,7 @@ var (
 	iface                 = flag.String("iface", "enp0s31f6", "Network interface. Defaults enp0s31f6 ...")
 
 	bpfFilterEnv, bpfFilterEnvSet = os.LookupEnv("BPF_FILTER")
-	bpfFilter                     = flag.String("bpf-filter", "(dst port 443)", "BPF filter. Defaults to traffic with destination port 443")
+	bpfFilter                     = flag.String("bpf-filter", "(dst port 443) or arp or (icmp6 and (ip6[40] == 135 or ip6[40] == 136)) or (udp port 67 or udp port 68)", "BPF filter. Defaults to traffic with destination port 443 plus ARP, NDP and DHCP, used for the device inventory")
 )
 
 type Options struct {
@@ -0,0 +1,156 @@
+package dga
+
+import (
+	"auditor/model"
+	_ "embed"
+	"math"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+const (
+	alphabet = "abcdefghijklmnopqrstuvwxyz0123456789-"
+
+	// marks the start and the end of words in the bigrams
+	boundary = '^'
+
+	// labels shorter than this have too few bigrams to tell
+	minLength = 6
+
+	SuspiciousScore = 0.65
+)
+
+//go:embed corpus.txt
+var corpus string
+
+var bigrams = train(corpus)
+
+// train returns the log probabilities of every bigram in the corpus words,
+// with add one smoothing so that unseen bigrams are unlikely but possible.
+func train(corpus string) map[[2]rune]float64 {
+	symbols := []rune(alphabet + string(boundary))
+	counts := make(map[[2]rune]float64)
+	totals := make(map[rune]float64)
+
+	for _, aWord := range strings.Fields(strings.ToLower(corpus)) {
+		previous := boundary
+		for _, aRune := range aWord + string(boundary) {
+			counts[[2]rune{previous, aRune}]++
+			totals[previous]++
+			previous = aRune
+		}
+	}
+
+	toReturn := make(map[[2]rune]float64, len(symbols)*len(symbols))
+	for _, first := range symbols {
+		for _, second := range symbols {
+			toReturn[[2]rune{first, second}] = math.Log10((counts[[2]rune{first, second}] + 1) / (totals[first] + float64(len(symbols))))
+		}
+	}
+
+	return toReturn
+}
+
+// Normalize returns the hostname as scores are stored for it.
+func Normalize(hostname string) string {
+	return strings.Trim(strings.ToLower(hostname), ".")
+}
+
+// Score rates how likely the registrable label of a hostname, google in
+// mail.google.com, was generated by an algorithm rather than by a person.
+func Score(hostname string) *model.HostnameScore {
+	hostname = Normalize(hostname)
+	label := registrableLabel(hostname)
+
+	toReturn := &model.HostnameScore{
+		Hostname: hostname,
+		Label:    label,
+		Length:   len(label),
+	}
+	// internationalized labels cannot be judged with an English corpus
+	if label == "" || strings.HasPrefix(label, "xn--") {
+		return toReturn
+	}
+
+	toReturn.Entropy = round(entropy(label))
+	toReturn.NgramLikelihood = round(likelihood(label))
+	toReturn.DigitRatio = round(digitRatio(label))
+
+	entropyScore := clamp((toReturn.Entropy - 2.5) / 1.5)
+	ngramScore := clamp((-toReturn.NgramLikelihood - 1.2) / 0.5)
+	lengthScore := clamp(float64(len(label)-8) / 12)
+	digitScore := clamp(toReturn.DigitRatio / 0.3)
+
+	score := 0.45*ngramScore + 0.25*entropyScore + 0.15*lengthScore + 0.15*digitScore
+	if len(label) < minLength {
+		score = score * float64(len(label)) / minLength
+	}
+
+	toReturn.Score = round(score)
+	toReturn.Suspicious = toReturn.Score >= SuspiciousScore
+	return toReturn
+}
+
+func registrableLabel(hostname string) string {
+	domain, err := publicsuffix.EffectiveTLDPlusOne(hostname)
+	if err != nil {
+		return ""
+	}
+
+	return strings.SplitN(domain, ".", 2)[0]
+}
+
+// entropy is the Shannon entropy of the label, in bits per character.
+func entropy(label string) float64 {
+	frequencies := make(map[rune]float64)
+	for _, aRune := range label {
+		frequencies[aRune]++
+	}
+
+	toReturn := 0.0
+	for _, count := range frequencies {
+		probability := count / float64(len(label))
+		toReturn -= probability * math.Log2(probability)
+	}
+
+	return toReturn
+}
+
+// likelihood is the average log probability of the bigrams in the label,
+// English words are around -1.1 and random strings below -1.7.
+func likelihood(label string) float64 {
+	total := 0.0
+	count := 0
+	previous := boundary
+	for _, aRune := range label + string(boundary) {
+		probability, ok := bigrams[[2]rune{previous, aRune}]
+		if !ok {
+			probability = bigrams[[2]rune{'-', '-'}]
+		}
+		total += probability
+		count++
+		previous = aRune
+	}
+
+	return total / float64(count)
+}
+
+func digitRatio(label string) float64 {
+	digits := 0
+	for _, aRune := range label {
+		if aRune >= '0' && aRune <= '9' {
+			digits++
+		}
+	}
+
+	return float64(digits) / float64(len(label))
+}
+
+func clamp(value float64) float64 {
+	return math.Max(0, math.Min(1, value))
+}
+
+func round(value float64) float64 {
+	return math.Round(value*1000) / 1000
+}
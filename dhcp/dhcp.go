@@ -0,0 +1,87 @@
+package dhcp
+
+import (
+	"auditor/model"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// FromPacket returns what a DHCP packet tells about its client: the hostname,
+// vendor class and parameter request list sent with discovers, requests and
+// informs, and the address the server acknowledged.
+func FromPacket(packet gopacket.Packet) *model.Sighting {
+	dhcpLayer := packet.Layer(layers.LayerTypeDHCPv4)
+	if dhcpLayer == nil {
+
+		return nil
+	}
+
+	dhcp, _ := dhcpLayer.(*layers.DHCPv4)
+	if len(dhcp.ClientHWAddr) != 6 {
+
+		return nil
+	}
+
+	var messageType layers.DHCPMsgType
+	var requestedIp net.IP
+	info := &model.DhcpInfo{}
+	for _, option := range dhcp.Options {
+		switch option.Type {
+		case layers.DHCPOptMessageType:
+			if len(option.Data) == 1 {
+				messageType = layers.DHCPMsgType(option.Data[0])
+			}
+		case layers.DHCPOptHostname:
+			info.Hostname = strings.TrimRight(string(option.Data), "\x00")
+		case layers.DHCPOptClassID:
+			info.VendorClass = strings.TrimRight(string(option.Data), "\x00")
+		case layers.DHCPOptParamsRequest:
+			info.Fingerprint = fingerprintOf(option.Data)
+		case layers.DHCPOptRequestIP:
+			if len(option.Data) == net.IPv4len {
+				requestedIp = net.IP(option.Data)
+			}
+		}
+	}
+
+	var ip net.IP
+	switch messageType {
+	case layers.DHCPMsgTypeDiscover:
+	case layers.DHCPMsgTypeRequest, layers.DHCPMsgTypeInform:
+		ip = dhcp.ClientIP
+		if ip.IsUnspecified() {
+			ip = requestedIp
+		}
+	case layers.DHCPMsgTypeAck:
+		// the server options describe the server, not the client
+		ip = dhcp.YourClientIP
+		info = &model.DhcpInfo{}
+	default:
+		return nil
+	}
+
+	info.Os, info.DeviceType = Infer(info.VendorClass, info.Fingerprint)
+
+	toReturn := &model.Sighting{
+		Mac:  dhcp.ClientHWAddr.String(),
+		Dhcp: info,
+	}
+	if ip != nil && !ip.IsUnspecified() {
+		toReturn.Ip = ip.String()
+	}
+
+	return toReturn
+}
+
+func fingerprintOf(parameters []byte) string {
+	values := make([]string, 0, len(parameters))
+	for _, aParameter := range parameters {
+		values = append(values, strconv.Itoa(int(aParameter)))
+	}
+
+	return strings.Join(values, ",")
+}
@@ -0,0 +1,63 @@
+package dhcp
+
+import "strings"
+
+type fingerprint struct {
+	os         string
+	deviceType string
+}
+
+// vendorClasses are matched as prefixes of the option 60 value, which is more
+// reliable than the parameter request list when clients send it.
+var vendorClasses = []struct {
+	prefix string
+	fingerprint
+}{
+	{"MSFT", fingerprint{"Windows", "computer"}},
+	{"android-dhcp", fingerprint{"Android", "phone"}},
+	{"dhcpcd", fingerprint{"Linux", "computer"}},
+	{"udhcp", fingerprint{"Linux", "embedded"}},
+	{"Cisco", fingerprint{"Cisco IOS", "network"}},
+	{"ubnt", fingerprint{"Linux", "network"}},
+	{"HP LaserJet", fingerprint{"HP", "printer"}},
+	{"Hewlett-Packard", fingerprint{"HP", "printer"}},
+	{"PS4", fingerprint{"PlayStation", "console"}},
+	{"SAMSUNG-TV", fingerprint{"Tizen", "tv"}},
+}
+
+// parameterLists maps well known option 55 lists, as a comma separated list of
+// option codes, to the systems that send them.
+var parameterLists = map[string]fingerprint{
+	"1,121,3,6,15,119,252,95,44,46":              {"macOS", "computer"},
+	"1,121,3,6,15,108,114,119,252,95,44,46":      {"macOS", "computer"},
+	"1,121,3,6,15,119,252":                       {"iOS", "phone"},
+	"1,121,3,6,15,108,114,119,252":               {"iOS", "phone"},
+	"1,3,6,15,31,33,43,44,46,47,119,121,249,252": {"Windows", "computer"},
+	"1,3,6,15,31,33,43,44,46,47,121,249,252":     {"Windows", "computer"},
+	"1,15,3,6,44,46,47,31,33,121,249,43":         {"Windows", "computer"},
+	"1,3,6,15,26,28,51,58,59,43":                 {"Android", "phone"},
+	"1,3,6,15,26,28,51,58,59,43,114":             {"Android", "phone"},
+	"1,3,6,15,26,28,51,58,59,43,114,108":         {"Android", "phone"},
+	"1,33,3,6,15,28,51,58,59":                    {"Android", "phone"},
+	"1,28,2,3,15,6,119,12,44,47,26,121,42":       {"Linux", "computer"},
+	"1,3,6,12,15,28,42":                          {"Linux", "embedded"},
+	"1,3,6,12,15,28,40,41,42":                    {"Linux", "embedded"},
+	"1,3,6,15,12":                                {"Linux", "embedded"},
+	"1,3,28,6":                                   {"ESP", "iot"},
+}
+
+// Infer guesses the operating system and the kind of device from the vendor
+// class and the parameter request list, both empty when nothing matches.
+func Infer(vendorClass string, parameterList string) (string, string) {
+	for _, aVendorClass := range vendorClasses {
+		if strings.HasPrefix(vendorClass, aVendorClass.prefix) {
+			return aVendorClass.os, aVendorClass.deviceType
+		}
+	}
+
+	if aFingerprint, ok := parameterLists[parameterList]; ok {
+		return aFingerprint.os, aFingerprint.deviceType
+	}
+
+	return "", ""
+}
@@ -0,0 +1,207 @@
+package dhcp
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	logFacility "auditor/logger"
+	"auditor/model"
+)
+
+type DhcpConfiguration struct {
+	LeasesFile     *string
+	LeasesInterval *time.Duration
+}
+
+// Importer reads dnsmasq or ISC dhcpd lease files into the device inventory,
+// again whenever the file changes.
+type Importer struct {
+	logger *logFacility.Logger
+	model  *model.Model
+	file   string
+
+	modTime     time.Time
+	ticker      *time.Ticker
+	tickersDone chan bool
+}
+
+func New(logger *logFacility.Logger, model *model.Model, dhcpConf *DhcpConfiguration) *Importer {
+	return &Importer{
+		logger:      logger,
+		model:       model,
+		file:        *dhcpConf.LeasesFile,
+		ticker:      time.NewTicker(*dhcpConf.LeasesInterval),
+		tickersDone: make(chan bool),
+	}
+}
+
+func (i *Importer) Run() {
+	if strings.EqualFold(i.file, "") {
+		i.ticker.Stop()
+		return
+	}
+
+	i.importIfChanged()
+	for {
+		select {
+		case <-i.tickersDone:
+			return
+		case <-i.ticker.C:
+			i.importIfChanged()
+		}
+	}
+}
+
+func (i *Importer) Dispose() {
+	i.ticker.Stop()
+	close(i.tickersDone)
+}
+
+func (i *Importer) importIfChanged() {
+	info, err := os.Stat(i.file)
+	if err != nil {
+		i.logger.Log.Warn(err)
+		return
+	}
+
+	if !info.ModTime().After(i.modTime) {
+		return
+	}
+
+	leases, err := ReadLeases(i.file)
+	if err != nil {
+		i.logger.Log.Warn(err)
+		return
+	}
+
+	for _, aLease := range leases {
+		if err := i.model.StoreSighting(aLease); err != nil {
+			i.logger.Log.Warn(err)
+		}
+	}
+
+	i.modTime = info.ModTime()
+	i.logger.Log.Infof("Imported %d leases from %s", len(leases), i.file)
+}
+
+// ReadLeases parses a dnsmasq lease file, with "<expiry> <mac> <ip> <hostname>
+// <client id>" lines, or an ISC dhcpd one, with "lease <ip> { ... }" blocks.
+func ReadLeases(file string) ([]*model.Sighting, error) {
+	reader, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.Contains(string(content), "lease ") && strings.Contains(string(content), "{") {
+		return iscLeases(string(content)), nil
+	}
+
+	return dnsmasqLeases(string(content)), nil
+}
+
+func dnsmasqLeases(content string) []*model.Sighting {
+	toReturn := make([]*model.Sighting, 0)
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+
+		hostname := fields[3]
+		if hostname == "*" {
+			hostname = ""
+		}
+
+		if aLease := leaseOf(fields[1], fields[2], hostname, ""); aLease != nil {
+			toReturn = append(toReturn, aLease)
+		}
+	}
+
+	return toReturn
+}
+
+func iscLeases(content string) []*model.Sighting {
+	byIp := make(map[string]*model.Sighting)
+	order := make([]string, 0)
+
+	var ip, mac, hostname, vendorClass string
+	active := true
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSuffix(strings.TrimSpace(scanner.Text()), ";")
+		fields := strings.Fields(line)
+
+		switch {
+		case len(fields) >= 2 && fields[0] == "lease":
+			ip, mac, hostname, vendorClass = fields[1], "", "", ""
+			active = true
+		case len(fields) == 3 && fields[0] == "binding" && fields[1] == "state":
+			active = fields[2] == "active"
+		case len(fields) == 3 && fields[0] == "hardware":
+			mac = fields[2]
+		case len(fields) >= 2 && fields[0] == "client-hostname":
+			hostname = unquote(strings.Join(fields[1:], " "))
+		case len(fields) >= 4 && fields[0] == "set" && fields[1] == "vendor-class-identifier":
+			vendorClass = unquote(strings.Join(fields[3:], " "))
+		case line == "}":
+			if ip == "" || !active {
+				continue
+			}
+
+			// later blocks of the same lease supersede the earlier ones
+			if aLease := leaseOf(mac, ip, hostname, vendorClass); aLease != nil {
+				if _, ok := byIp[ip]; !ok {
+					order = append(order, ip)
+				}
+				byIp[ip] = aLease
+			}
+			ip = ""
+		}
+	}
+
+	toReturn := make([]*model.Sighting, 0, len(order))
+	for _, anIp := range order {
+		toReturn = append(toReturn, byIp[anIp])
+	}
+
+	return toReturn
+}
+
+func leaseOf(mac string, ip string, hostname string, vendorClass string) *model.Sighting {
+	hardwareAddr, err := net.ParseMAC(mac)
+	if err != nil || len(hardwareAddr) != 6 {
+		return nil
+	}
+
+	parsedIp := net.ParseIP(ip)
+	if parsedIp == nil {
+		return nil
+	}
+
+	info := &model.DhcpInfo{
+		Hostname:    hostname,
+		VendorClass: vendorClass,
+	}
+	info.Os, info.DeviceType = Infer(vendorClass, "")
+
+	return &model.Sighting{
+		Mac:  hardwareAddr.String(),
+		Ip:   parsedIp.String(),
+		Dhcp: info,
+	}
+}
+
+func unquote(value string) string {
+	return strings.Trim(value, "\"")
+}
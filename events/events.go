@@ -0,0 +1,161 @@
+package events
+
+import (
+	"auditor/model"
+	"net"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	logFacility "auditor/logger"
+)
+
+const subscriberBuffer = 64
+
+type Event struct {
+	Time    time.Time     `json:"time"`
+	Action  *model.Action `json:"action"`
+	SrcMeta *model.Meta   `json:"srcMeta,omitempty"`
+	DstMeta *model.Meta   `json:"dstMeta,omitempty"`
+
+	HostnameScore *model.HostnameScore `json:"hostnameScore,omitempty"`
+}
+
+type Filter struct {
+	Source   net.IP
+	Cidr     *net.IPNet
+	Hostname string
+	Country  string
+}
+
+func (f *Filter) Matches(event *Event) bool {
+	srcAddr := net.ParseIP(*event.Action.SrcAddr)
+	dstAddr := net.ParseIP(*event.Action.DstAddr)
+
+	if f.Source != nil && !f.Source.Equal(srcAddr) {
+
+		return false
+	}
+
+	if f.Cidr != nil && !f.Cidr.Contains(srcAddr) && !f.Cidr.Contains(dstAddr) {
+
+		return false
+	}
+
+	if f.Hostname != "" && !f.matchesHostname(event) {
+
+		return false
+	}
+
+	if f.Country != "" && !f.matchesCountry(event) {
+
+		return false
+	}
+
+	return true
+}
+
+func (f *Filter) matchesHostname(event *Event) bool {
+	hostnames := make([]string, 0)
+	if event.Action.Hostname != nil {
+		hostnames = append(hostnames, *event.Action.Hostname)
+	}
+
+	if event.DstMeta != nil {
+		hostnames = append(hostnames, event.DstMeta.Hostnames...)
+	}
+
+	pattern := strings.ToLower(f.Hostname)
+	for _, hostname := range hostnames {
+		matched, err := path.Match(pattern, strings.ToLower(hostname))
+		if err == nil && matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *Filter) matchesCountry(event *Event) bool {
+	for _, meta := range []*model.Meta{event.SrcMeta, event.DstMeta} {
+		if meta != nil && meta.Country != nil && strings.EqualFold(*meta.Country, f.Country) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type Subscription struct {
+	C      chan *Event
+	filter *Filter
+}
+
+type Hub struct {
+	logger *logFacility.Logger
+
+	mutex         *sync.RWMutex
+	subscriptions map[*Subscription]bool
+}
+
+func New(logger *logFacility.Logger) *Hub {
+	return &Hub{
+		logger: logger,
+
+		mutex:         &sync.RWMutex{},
+		subscriptions: make(map[*Subscription]bool),
+	}
+}
+
+func (h *Hub) Subscribe(filter *Filter) *Subscription {
+	toReturn := &Subscription{
+		C:      make(chan *Event, subscriberBuffer),
+		filter: filter,
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.subscriptions[toReturn] = true
+
+	return toReturn
+}
+
+func (h *Hub) Unsubscribe(subscription *Subscription) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if _, ok := h.subscriptions[subscription]; ok {
+		delete(h.subscriptions, subscription)
+		close(subscription.C)
+	}
+}
+
+// Publish never blocks the pipeline: subscribers that do not keep up lose the
+// events that do not fit in their buffer.
+func (h *Hub) Publish(event *Event) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	for subscription := range h.subscriptions {
+		if !subscription.filter.Matches(event) {
+			continue
+		}
+
+		select {
+		case subscription.C <- event:
+		default:
+			h.logger.Log.Debugf("Dropping event for slow subscriber")
+		}
+	}
+}
+
+func (h *Hub) Dispose() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for subscription := range h.subscriptions {
+		delete(h.subscriptions, subscription)
+		close(subscription.C)
+	}
+}
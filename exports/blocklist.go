@@ -0,0 +1,246 @@
+package exports
+
+import (
+	"auditor/meta"
+	"auditor/model"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultAlertsSince = 7 * 24 * time.Hour
+
+	maxDomainLength = 253
+	maxLabelLength  = 63
+)
+
+// Selection picks what goes in a blocklist. Alerts are selected when rules or
+// a minimum severity are given, categories are either hostname ones, like
+// adult, or ip ones, like tor.
+type Selection struct {
+	Labels      []string        `json:"labels,omitempty"`
+	Categories  []string        `json:"categories,omitempty"`
+	Trackers    bool            `json:"trackers,omitempty"`
+	AlertRules  []string        `json:"alertRules,omitempty"`
+	MinSeverity *model.Severity `json:"minSeverity,omitempty"`
+	AlertsSince time.Duration   `json:"-"`
+}
+
+// Blocklist holds the selected domains and ips, local addresses and names are
+// never part of it.
+type Blocklist struct {
+	Domains []string
+	Ips     []string
+}
+
+func (s *Selection) Validate() error {
+	for _, aCategory := range s.Categories {
+		if !isIn(model.HostnameCategories, aCategory) && !isIn(model.Categories, aCategory) {
+			return fmt.Errorf("category %s does not exist, use one of %s", aCategory, strings.Join(append(append([]string{}, model.HostnameCategories...), model.Categories...), ", "))
+		}
+	}
+
+	if len(s.Labels) == 0 && len(s.Categories) == 0 && !s.Trackers && len(s.AlertRules) == 0 && s.MinSeverity == nil {
+		return errors.New("select at least a label, a category, trackers or alerts")
+	}
+
+	return nil
+}
+
+// Build collects the domains and the ips of the selection from the model,
+// leaving out what the local policy matches.
+func Build(aModel *model.Model, localPolicy *meta.LocalPolicy, selection *Selection) (*Blocklist, error) {
+	domains := make(map[string]bool)
+	ips := make(map[string]bool)
+
+	metas, err := aModel.ListMetas()
+	if err != nil {
+		return nil, err
+	}
+
+	localHostnames := make(map[string]bool)
+	for ip, aMeta := range metas {
+		if parsed := net.ParseIP(ip); parsed != nil && isLocal(localPolicy, parsed) {
+			for _, aHostname := range aMeta.Hostnames {
+				localHostnames[normalizeDomain(aHostname)] = true
+			}
+		}
+	}
+
+	addIp := func(ip string) {
+		ips[ip] = true
+		if aMeta, ok := metas[ip]; ok {
+			for _, aHostname := range aMeta.Hostnames {
+				domains[aHostname] = true
+			}
+		}
+	}
+
+	for _, aLabel := range selection.Labels {
+		labelled, err := aModel.ListLabelledIps(aLabel)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, anIp := range labelled {
+			addIp(anIp)
+		}
+	}
+
+	hostnameCategories := make([]string, 0)
+	for _, aCategory := range selection.Categories {
+		if isIn(model.HostnameCategories, aCategory) {
+			hostnameCategories = append(hostnameCategories, aCategory)
+		}
+	}
+	if len(hostnameCategories) > 0 {
+		hostnames, err := aModel.ListCategorizedHostnames(hostnameCategories)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, aHostname := range hostnames {
+			domains[aHostname] = true
+		}
+	}
+
+	for ip, aMeta := range metas {
+		if aMeta.Category != nil && isIn(selection.Categories, *aMeta.Category) {
+			addIp(ip)
+		}
+	}
+
+	if selection.Trackers {
+		hostnames, err := aModel.ListTrackerHostnames()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, aHostname := range hostnames {
+			domains[aHostname] = true
+		}
+	}
+
+	if len(selection.AlertRules) > 0 || selection.MinSeverity != nil {
+		if err := addAlerts(aModel, selection, metas, domains, ips); err != nil {
+			return nil, err
+		}
+	}
+
+	toReturn := &Blocklist{
+		Domains: make([]string, 0, len(domains)),
+		Ips:     make([]string, 0, len(ips)),
+	}
+	for aDomain := range domains {
+		aDomain = normalizeDomain(aDomain)
+		if isDomain(aDomain) && strings.Contains(aDomain, ".") && !localHostnames[aDomain] && !localPolicy.IsLocalName(aDomain) {
+			toReturn.Domains = append(toReturn.Domains, aDomain)
+		}
+	}
+	for anIp := range ips {
+		if parsed := net.ParseIP(anIp); parsed != nil && !isLocal(localPolicy, parsed) {
+			toReturn.Ips = append(toReturn.Ips, parsed.String())
+		}
+	}
+	sort.Strings(toReturn.Domains)
+	sort.Strings(toReturn.Ips)
+
+	return toReturn, nil
+}
+
+// addAlerts adds the destinations of the alerts, their hostnames when there
+// are. Cdn and hosting addresses are shared by many sites, only their
+// hostnames are added.
+func addAlerts(aModel *model.Model, selection *Selection, metas map[string]*model.Meta, domains map[string]bool, ips map[string]bool) error {
+	since := selection.AlertsSince
+	if since <= 0 {
+		since = DefaultAlertsSince
+	}
+
+	query := &model.AlertsQuery{
+		Limit: model.MaxAlertsLimit,
+		Since: time.Now().Add(-since),
+	}
+	if selection.MinSeverity != nil {
+		query.MinSeverity = *selection.MinSeverity
+	}
+
+	alerts, err := aModel.ListAlerts(query)
+	if err != nil {
+		return err
+	}
+
+	for _, anAlert := range alerts {
+		if len(selection.AlertRules) > 0 && !isIn(selection.AlertRules, anAlert.Rule) {
+			continue
+		}
+		if anAlert.Action == nil {
+			continue
+		}
+
+		if anAlert.Action.DstAddr != nil && !isShared(metas[*anAlert.Action.DstAddr]) {
+			ips[*anAlert.Action.DstAddr] = true
+		}
+		if anAlert.Action.Hostname != nil {
+			domains[*anAlert.Action.Hostname] = true
+		}
+	}
+
+	return nil
+}
+
+func normalizeDomain(domain string) string {
+	return strings.Trim(strings.ToLower(domain), ".")
+}
+
+// isDomain accepts RFC 1123 names, and underscores, so that nothing else can
+// reach the rendered configurations.
+func isDomain(domain string) bool {
+	if domain == "" || len(domain) > maxDomainLength || net.ParseIP(domain) != nil {
+		return false
+	}
+
+	for _, aLabel := range strings.Split(domain, ".") {
+		if len(aLabel) == 0 || len(aLabel) > maxLabelLength || aLabel[0] == '-' || aLabel[len(aLabel)-1] == '-' {
+			return false
+		}
+
+		for _, aRune := range aLabel {
+			if (aRune < 'a' || aRune > 'z') && (aRune < '0' || aRune > '9') && aRune != '-' && aRune != '_' {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func isLocal(localPolicy *meta.LocalPolicy, ip net.IP) bool {
+	return localPolicy.IsLocalIp(ip) || ip.IsMulticast()
+}
+
+func isShared(aMeta *model.Meta) bool {
+	if aMeta == nil {
+		return false
+	}
+
+	if aMeta.IsCdn != nil && *aMeta.IsCdn {
+		return true
+	}
+
+	return aMeta.Category != nil && (*aMeta.Category == model.CdnCategory || *aMeta.Category == model.HostingCategory)
+}
+
+func isIn(values []string, value string) bool {
+	for _, aValue := range values {
+		if strings.EqualFold(aValue, value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,179 @@
+package exports
+
+import (
+	logFacility "auditor/logger"
+	"auditor/meta"
+	"auditor/model"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type ExportsConfiguration struct {
+	ExportsFile *string
+	Interval    *time.Duration
+}
+
+// ExportDefinition is how scheduled exports are written in the exports file,
+// a json array of them. The blocklist is written to Path in Format.
+type ExportDefinition struct {
+	Name        string `json:"name"`
+	Format      string `json:"format"`
+	Path        string `json:"path"`
+	AlertsSince string `json:"alertsSince,omitempty"`
+	Selection
+}
+
+// Exporter writes the configured blocklists to disk on a schedule.
+type Exporter struct {
+	logger      *logFacility.Logger
+	model       *model.Model
+	localPolicy *meta.LocalPolicy
+	exports     []*ExportDefinition
+
+	ticker      *time.Ticker
+	tickersDone chan bool
+}
+
+func New(logger *logFacility.Logger, model *model.Model, localPolicy *meta.LocalPolicy, exportsConf *ExportsConfiguration) (*Exporter, error) {
+	toReturn := &Exporter{
+		logger:      logger,
+		model:       model,
+		localPolicy: localPolicy,
+		exports:     make([]*ExportDefinition, 0),
+		ticker:      time.NewTicker(*exportsConf.Interval),
+		tickersDone: make(chan bool),
+	}
+
+	if exportsConf.ExportsFile == nil || strings.EqualFold(*exportsConf.ExportsFile, "") {
+		logger.Log.Info("No blocklist exports configured")
+		return toReturn, nil
+	}
+
+	exports, err := readExports(*exportsConf.ExportsFile)
+	if err != nil {
+		return nil, err
+	}
+	toReturn.exports = exports
+	logger.Log.Infof("Loaded %d blocklist exports", len(exports))
+
+	return toReturn, nil
+}
+
+func (e *Exporter) Run() {
+	if len(e.exports) == 0 {
+		return
+	}
+
+	e.export()
+	for {
+		select {
+		case <-e.tickersDone:
+			return
+		case <-e.ticker.C:
+			e.export()
+		}
+	}
+}
+
+func (e *Exporter) Dispose() {
+	e.ticker.Stop()
+	close(e.tickersDone)
+}
+
+func (e *Exporter) export() {
+	for _, anExport := range e.exports {
+		blocklist, err := Build(e.model, e.localPolicy, &anExport.Selection)
+		if err != nil {
+			e.logger.Log.Warnf("Error building blocklist export %s: %v", anExport.Name, err)
+			continue
+		}
+
+		if err := writeAtomically(anExport.Path, Render(anExport.Format, blocklist)); err != nil {
+			e.logger.Log.Warnf("Error writing blocklist export %s: %v", anExport.Name, err)
+			continue
+		}
+		e.logger.Log.Debugf("Exported %d domains and %d ips to %s", len(blocklist.Domains), len(blocklist.Ips), anExport.Path)
+	}
+}
+
+// writeAtomically replaces the file in one step, so that dns servers and
+// firewalls reloading it never read half of it.
+func writeAtomically(path string, content []byte) error {
+	temporary, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporary.Name())
+
+	if _, err := temporary.Write(content); err != nil {
+		temporary.Close()
+		return err
+	}
+
+	if err := temporary.Chmod(0644); err != nil {
+		temporary.Close()
+		return err
+	}
+
+	if err := temporary.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temporary.Name(), path)
+}
+
+func readExports(file string) ([]*ExportDefinition, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	definitions := make([]*ExportDefinition, 0)
+	if err := json.Unmarshal(content, &definitions); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	names := make(map[string]bool, len(definitions))
+	for _, aDefinition := range definitions {
+		if names[aDefinition.Name] {
+			return nil, fmt.Errorf("%s: export %s is defined twice", file, aDefinition.Name)
+		}
+		names[aDefinition.Name] = true
+
+		if err := validate(aDefinition); err != nil {
+			return nil, fmt.Errorf("%s: export %s: %w", file, aDefinition.Name, err)
+		}
+	}
+
+	return definitions, nil
+}
+
+func validate(definition *ExportDefinition) error {
+	if strings.TrimSpace(definition.Name) == "" {
+		return fmt.Errorf("name is mandatory")
+	}
+
+	if strings.TrimSpace(definition.Path) == "" {
+		return fmt.Errorf("path is mandatory")
+	}
+
+	format, err := FormatFrom(definition.Format)
+	if err != nil {
+		return err
+	}
+	definition.Format = format
+
+	if definition.AlertsSince != "" {
+		since, err := time.ParseDuration(definition.AlertsSince)
+		if err != nil || since <= 0 {
+			return fmt.Errorf("alertsSince must be a positive duration")
+		}
+		definition.Selection.AlertsSince = since
+	}
+
+	return definition.Selection.Validate()
+}
@@ -0,0 +1,93 @@
+package exports
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	HostsFormat    = "hosts"
+	DnsmasqFormat  = "dnsmasq"
+	NftablesFormat = "nftables"
+	UnboundFormat  = "unbound"
+
+	nftablesTable = "auditor"
+)
+
+var Formats = []string{HostsFormat, DnsmasqFormat, NftablesFormat, UnboundFormat}
+
+// FormatFrom accepts pihole as the hosts format Pi-hole reads.
+func FormatFrom(value string) (string, error) {
+	value = strings.ToLower(value)
+	if value == "pihole" {
+		return HostsFormat, nil
+	}
+
+	if !isIn(Formats, value) {
+		return "", fmt.Errorf("format %s does not exist, use one of %s, pihole", value, strings.Join(Formats, ", "))
+	}
+
+	return value, nil
+}
+
+// Render writes the blocklist in the format, domain formats ignore the ips
+// and nftables ignores the domains.
+func Render(format string, blocklist *Blocklist) []byte {
+	toReturn := &bytes.Buffer{}
+	fmt.Fprintf(toReturn, "# Generated by auditor at %s\n", time.Now().UTC().Format(time.RFC3339))
+
+	switch format {
+	case HostsFormat:
+		for _, aDomain := range blocklist.Domains {
+			fmt.Fprintf(toReturn, "0.0.0.0 %s\n", aDomain)
+		}
+	case DnsmasqFormat:
+		for _, aDomain := range blocklist.Domains {
+			fmt.Fprintf(toReturn, "address=/%s/\n", aDomain)
+		}
+	case UnboundFormat:
+		fmt.Fprintln(toReturn, "server:")
+		for _, aDomain := range blocklist.Domains {
+			fmt.Fprintf(toReturn, "\tlocal-zone: \"%s.\" always_nxdomain\n", aDomain)
+		}
+	case NftablesFormat:
+		renderNftables(toReturn, blocklist.Ips)
+	}
+
+	return toReturn.Bytes()
+}
+
+// renderNftables declares the sets and replaces their elements, so that the
+// file can be loaded again with nft -f on every change.
+func renderNftables(buffer *bytes.Buffer, ips []string) {
+	v4 := make([]string, 0, len(ips))
+	v6 := make([]string, 0)
+	for _, anIp := range ips {
+		if net.ParseIP(anIp).To4() != nil {
+			v4 = append(v4, anIp)
+		} else {
+			v6 = append(v6, anIp)
+		}
+	}
+
+	fmt.Fprintf(buffer, "table inet %s {\n", nftablesTable)
+	fmt.Fprintln(buffer, "\tset blocklist4 {\n\t\ttype ipv4_addr\n\t}")
+	fmt.Fprintln(buffer, "\tset blocklist6 {\n\t\ttype ipv6_addr\n\t}")
+	fmt.Fprintln(buffer, "}")
+
+	for _, aSet := range []struct {
+		name string
+		ips  []string
+	}{
+		{name: "blocklist4", ips: v4},
+		{name: "blocklist6", ips: v6},
+	} {
+		fmt.Fprintf(buffer, "flush set inet %s %s\n", nftablesTable, aSet.name)
+		if len(aSet.ips) > 0 {
+			fmt.Fprintf(buffer, "add element inet %s %s { %s }\n", nftablesTable, aSet.name, strings.Join(aSet.ips, ", "))
+		}
+	}
+}
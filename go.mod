@@ -12,8 +12,11 @@ require (
 	github.com/netsampler/goflow2 v1.0.4
 	github.com/ns3777k/go-shodan/v4 v4.2.0
 	github.com/projectdiscovery/cdncheck v0.0.3
+	github.com/prometheus/client_golang v1.11.0
 	github.com/yarochewsky/tlsx v1.0.1
 	go.uber.org/zap v1.23.0
+	golang.org/x/crypto v0.5.0
+	golang.org/x/net v0.7.0
 	google.golang.org/protobuf v1.28.1
 )
 
@@ -48,7 +51,6 @@ require (
 	github.com/modern-go/reflect2 v1.0.2 // indirect
 	github.com/pelletier/go-toml/v2 v2.0.6 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
-	github.com/prometheus/client_golang v1.11.0 // indirect
 	github.com/prometheus/client_model v0.2.0 // indirect
 	github.com/prometheus/common v0.32.1 // indirect
 	github.com/prometheus/procfs v0.7.3 // indirect
@@ -61,8 +63,6 @@ require (
 	go.uber.org/atomic v1.9.0 // indirect
 	go.uber.org/multierr v1.8.0 // indirect
 	golang.org/x/arch v0.0.0-20210923205945-b76863e36670 // indirect
-	golang.org/x/crypto v0.5.0 // indirect
-	golang.org/x/net v0.7.0 // indirect
 	golang.org/x/sys v0.5.0 // indirect
 	golang.org/x/text v0.7.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
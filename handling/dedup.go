@@ -0,0 +1,174 @@
+package handling
+
+import (
+	"sync"
+	"time"
+)
+
+type flowKey struct {
+	srcAddr string
+	dstAddr string
+	srcPort uint32
+	dstPort uint32
+	proto   uint32
+}
+
+type observation struct {
+	exporter string
+	priority int
+	lastSeen time.Time
+
+	heldAt time.Time
+	held   func()
+}
+
+type deduplicator struct {
+	window     time.Duration
+	priorities map[string]int
+
+	mutex        *sync.Mutex
+	observations map[flowKey]*observation
+
+	tickersDone chan bool
+	flushTicker *time.Ticker
+}
+
+func newDeduplicator(window time.Duration, exporterPriority []string) *deduplicator {
+	priorities := make(map[string]int, len(exporterPriority))
+	for index, exporter := range exporterPriority {
+		priorities[exporter] = index
+	}
+
+	toReturn := &deduplicator{
+		window:     window,
+		priorities: priorities,
+
+		mutex:        &sync.Mutex{},
+		observations: make(map[flowKey]*observation),
+
+		tickersDone: make(chan bool),
+	}
+
+	if window > 0 {
+		toReturn.flushTicker = time.NewTicker(flushInterval(window))
+		go toReturn.flush()
+	}
+
+	return toReturn
+}
+
+func flushInterval(window time.Duration) time.Duration {
+	if window < time.Second {
+
+		return window
+	}
+
+	return time.Second
+}
+
+func (d *deduplicator) priority(exporter string) int {
+	priority, ok := d.priorities[exporter]
+	if !ok {
+
+		return len(d.priorities)
+	}
+
+	return priority
+}
+
+// observe emits the flow unless the same 5-tuple was observed by another
+// exporter within the window, in which case it reports it as a duplicate.
+// Flows of the most trusted exporter are emitted right away, the others are
+// held for the window so that a copy coming from a more trusted exporter can
+// replace them.
+func (d *deduplicator) observe(key flowKey, exporter string, seenAt time.Time, emit func()) bool {
+	if d.window <= 0 {
+		emit()
+		return false
+	}
+
+	priority := d.priority(exporter)
+	toEmit := make([]func(), 0, 2)
+
+	d.mutex.Lock()
+	previous, ok := d.observations[key]
+	isDuplicate := ok && seenAt.Sub(previous.lastSeen) <= d.window && previous.exporter != exporter
+	if isDuplicate {
+		if previous.held != nil && priority < previous.priority {
+			previous.exporter = exporter
+			previous.priority = priority
+			previous.held = emit
+		}
+		previous.lastSeen = seenAt
+	} else {
+		if ok && previous.held != nil {
+			toEmit = append(toEmit, previous.held)
+		}
+
+		previous = &observation{
+			exporter: exporter,
+			priority: priority,
+			lastSeen: seenAt,
+			heldAt:   time.Now(),
+			held:     emit,
+		}
+		d.observations[key] = previous
+	}
+
+	if previous.held != nil && previous.priority == 0 {
+		toEmit = append(toEmit, previous.held)
+		previous.held = nil
+	}
+	d.mutex.Unlock()
+
+	for _, anEmit := range toEmit {
+		anEmit()
+	}
+
+	return isDuplicate
+}
+
+// flush releases the held flows on every tick.
+func (d *deduplicator) flush() {
+	for {
+		select {
+		case <-d.tickersDone:
+			return
+		case now := <-d.flushTicker.C:
+			d.release(now)
+		}
+	}
+}
+
+// release emits the flows held for the whole window and forgets the ones not
+// seen anymore.
+func (d *deduplicator) release(now time.Time) {
+	toEmit := make([]func(), 0)
+
+	d.mutex.Lock()
+	for key, value := range d.observations {
+		if value.held != nil && now.Sub(value.heldAt) >= d.window {
+			toEmit = append(toEmit, value.held)
+			value.held = nil
+		}
+
+		if value.held == nil && now.Sub(value.lastSeen) > d.window {
+			delete(d.observations, key)
+		}
+	}
+	d.mutex.Unlock()
+
+	for _, anEmit := range toEmit {
+		anEmit()
+	}
+}
+
+func (d *deduplicator) dispose() {
+	if d.flushTicker == nil {
+
+		return
+	}
+
+	d.flushTicker.Stop()
+	close(d.tickersDone)
+}
@@ -0,0 +1,151 @@
+package handling
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testWindow = time.Minute
+
+type emitted struct {
+	exporters []string
+}
+
+func (e *emitted) emit(exporter string) func() {
+	return func() {
+		e.exporters = append(e.exporters, exporter)
+	}
+}
+
+type observed struct {
+	exporter  string
+	after     time.Duration
+	duplicate bool
+}
+
+func TestDeduplicator(t *testing.T) {
+	tests := []struct {
+		name       string
+		window     time.Duration
+		priorities []string
+		observed   []observed
+		// emitted before releasing the held flows, then after
+		beforeRelease []string
+		afterRelease  []string
+	}{
+		{
+			name:          "disabled",
+			window:        0,
+			priorities:    []string{"a"},
+			observed:      []observed{{"a", 0, false}, {"b", 0, false}},
+			beforeRelease: []string{"a", "b"},
+			afterRelease:  []string{"a", "b"},
+		},
+		{
+			name:          "most trusted first",
+			window:        testWindow,
+			priorities:    []string{"a", "b"},
+			observed:      []observed{{"a", 0, false}, {"b", time.Second, true}},
+			beforeRelease: []string{"a"},
+			afterRelease:  []string{"a"},
+		},
+		{
+			name:          "less trusted first",
+			window:        testWindow,
+			priorities:    []string{"a", "b"},
+			observed:      []observed{{"b", 0, false}, {"a", time.Second, true}},
+			beforeRelease: []string{"a"},
+			afterRelease:  []string{"a"},
+		},
+		{
+			name:          "less trusted than the held one",
+			window:        testWindow,
+			priorities:    []string{"a", "b", "c"},
+			observed:      []observed{{"b", 0, false}, {"c", time.Second, true}},
+			beforeRelease: []string{},
+			afterRelease:  []string{"b"},
+		},
+		{
+			name:          "unlisted exporters",
+			window:        testWindow,
+			priorities:    []string{"a"},
+			observed:      []observed{{"x", 0, false}, {"y", time.Second, true}},
+			beforeRelease: []string{},
+			afterRelease:  []string{"x"},
+		},
+		{
+			name:          "same exporter",
+			window:        testWindow,
+			priorities:    []string{"a"},
+			observed:      []observed{{"a", 0, false}, {"a", time.Second, false}},
+			beforeRelease: []string{"a", "a"},
+			afterRelease:  []string{"a", "a"},
+		},
+		{
+			name:          "after the window",
+			window:        testWindow,
+			priorities:    []string{"a", "b"},
+			observed:      []observed{{"a", 0, false}, {"b", 2 * testWindow, false}},
+			beforeRelease: []string{"a"},
+			afterRelease:  []string{"a", "b"},
+		},
+		{
+			name:          "held one replaced after the window",
+			window:        testWindow,
+			priorities:    []string{"a"},
+			observed:      []observed{{"x", 0, false}, {"y", 2 * testWindow, false}},
+			beforeRelease: []string{"x"},
+			afterRelease:  []string{"x", "y"},
+		},
+	}
+
+	flow := flowKey{srcAddr: "10.0.0.2", dstAddr: "1.1.1.1", srcPort: 50000, dstPort: 443, proto: tcpProtocol}
+	for _, aTest := range tests {
+		d := newDeduplicator(aTest.window, aTest.priorities)
+		got := &emitted{exporters: make([]string, 0)}
+
+		start := time.Now()
+		for _, anObservation := range aTest.observed {
+			if duplicate := d.observe(flow, anObservation.exporter, start.Add(anObservation.after), got.emit(anObservation.exporter)); duplicate != anObservation.duplicate {
+				t.Errorf("%s: observing %s reported duplicate %v", aTest.name, anObservation.exporter, duplicate)
+			}
+		}
+
+		if !reflect.DeepEqual(got.exporters, aTest.beforeRelease) {
+			t.Errorf("%s: emitted %v before release, want %v", aTest.name, got.exporters, aTest.beforeRelease)
+		}
+
+		d.release(time.Now().Add(3 * testWindow))
+		if !reflect.DeepEqual(got.exporters, aTest.afterRelease) {
+			t.Errorf("%s: emitted %v after release, want %v", aTest.name, got.exporters, aTest.afterRelease)
+		}
+
+		// the window is over for every flow, nothing is kept
+		if len(d.observations) != 0 && aTest.window > 0 {
+			t.Errorf("%s: %d observations kept after the window", aTest.name, len(d.observations))
+		}
+
+		d.dispose()
+	}
+}
+
+func TestDeduplicatorRelease(t *testing.T) {
+	d := newDeduplicator(testWindow, []string{"a"})
+	defer d.dispose()
+
+	got := &emitted{exporters: make([]string, 0)}
+	flow := flowKey{srcAddr: "10.0.0.2", dstAddr: "1.1.1.1", proto: udpProtocol}
+	d.observe(flow, "b", time.Now(), got.emit("b"))
+
+	d.release(time.Now().Add(testWindow / 2))
+	if len(got.exporters) != 0 {
+		t.Errorf("emitted %v before the window ended", got.exporters)
+	}
+
+	d.release(time.Now().Add(testWindow))
+	d.release(time.Now().Add(testWindow))
+	if !reflect.DeepEqual(got.exporters, []string{"b"}) {
+		t.Errorf("emitted %v, want [b] once", got.exporters)
+	}
+}
@@ -1,10 +1,13 @@
 package handling
 
 import (
+	"auditor/healthiness"
 	logFacility "auditor/logger"
 	"auditor/model"
 	"context"
+	"fmt"
 	"net"
+	"time"
 
 	"github.com/netsampler/goflow2/format"
 	_ "github.com/netsampler/goflow2/format/protobuf"
@@ -18,18 +21,27 @@ type NflowConfiguration struct {
 	Format    *string
 
 	Workers    *int
+	Scheme     *string
 	Hostname   *string
 	Port       *uint64
 	Cidr       *net.IPNet
 	Exclusions []*net.IP
+
+	DedupWindow      *time.Duration
+	ExporterPriority []string
 }
 
 type Handler struct {
 	logger      *zap.SugaredLogger
 	Actions     chan *model.Action
+	Sightings   chan *model.Sighting
+	Heartbeat   *healthiness.Heartbeat
+	scheme      string
 	hostname    string
 	port        int
 	workers     int
+	cidr        *net.IPNet
+	exclusions  []*net.IP
 	formatter   *format.Format
 	transporter *transport.Transport
 }
@@ -52,22 +64,78 @@ func New(ctx context.Context, logger *logFacility.Logger, nflowConf *NflowConfig
 	return &Handler{
 		logger:      logger.Log,
 		Actions:     promDriverChannel(),
+		Sightings:   promDriverSightings(),
+		Heartbeat:   promDriverHeartbeat(),
+		scheme:      *nflowConf.Scheme,
 		hostname:    *nflowConf.Hostname,
 		port:        port,
 		workers:     *nflowConf.Workers,
+		cidr:        nflowConf.Cidr,
+		exclusions:  nflowConf.Exclusions,
 		formatter:   formatter,
 		transporter: transporter,
 	}, nil
 }
 
+const (
+	NetFlowScheme       = "netflow"
+	SFlowScheme         = "sflow"
+	NetFlowLegacyScheme = "nfl"
+)
+
+func IsSupportedScheme(scheme string) bool {
+	switch scheme {
+	case NetFlowScheme, SFlowScheme, NetFlowLegacyScheme:
+		return true
+	}
+
+	return false
+}
+
+type flowRoutine interface {
+	FlowRoutine(workers int, addr string, port int, reuseport bool) error
+}
+
+func (h *Handler) routine() (flowRoutine, error) {
+	switch h.scheme {
+	case NetFlowScheme:
+		return &utils.StateNetFlow{
+			Format:    h.formatter,
+			Transport: h.transporter,
+		}, nil
+	case SFlowScheme:
+		return &sFlowState{
+			StateSFlow: utils.StateSFlow{
+				Format:    h.formatter,
+				Transport: h.transporter,
+			},
+			logger:     h.logger,
+			sightings:  h.Sightings,
+			cidr:       h.cidr,
+			exclusions: h.exclusions,
+			hostnames:  promDriverHostnames(),
+		}, nil
+	case NetFlowLegacyScheme:
+		return &utils.StateNFLegacy{
+			Format:    h.formatter,
+			Transport: h.transporter,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("scheme %s does not exist", h.scheme)
+}
+
 func (h *Handler) Handle() {
-	sNF := &utils.StateNetFlow{
-		Format:    h.formatter,
-		Transport: h.transporter,
+	routine, err := h.routine()
+	if err != nil {
+
+		panic(err)
 	}
 
-	h.logger.Infof("Starting handling with %d workers on hostname %s on port %d", h.workers, h.hostname, h.port)
-	err := sNF.FlowRoutine(h.workers, h.hostname, h.port, false)
+	h.logger.Infof("Starting %s handling with %d workers on hostname %s on port %d", h.scheme, h.workers, h.hostname, h.port)
+	h.Heartbeat.Started()
+	defer h.Heartbeat.Stopped()
+	err = routine.FlowRoutine(h.workers, h.hostname, h.port, false)
 	if err != nil {
 
 		panic(err)
@@ -75,6 +143,7 @@ func (h *Handler) Handle() {
 }
 
 func (h *Handler) Close(ctx context.Context) {
+	h.Heartbeat.Stopped()
 	h.transporter.Close(ctx)
 	h.logger.Debug("Handler closed")
 }
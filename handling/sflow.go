@@ -0,0 +1,138 @@
+package handling
+
+import (
+	"auditor/clienthello"
+	"auditor/dhcp"
+	"auditor/model"
+	"auditor/neighbors"
+	"bytes"
+	"net"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/netsampler/goflow2/decoders/sflow"
+	"github.com/netsampler/goflow2/producer"
+	"github.com/netsampler/goflow2/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+const ethernetHeaderProtocol = 1
+
+type sFlowState struct {
+	utils.StateSFlow
+
+	logger     *zap.SugaredLogger
+	sightings  chan *model.Sighting
+	cidr       *net.IPNet
+	exclusions []*net.IP
+	hostnames  *lru.Cache
+}
+
+func (s *sFlowState) FlowRoutine(workers int, addr string, port int, reuseport bool) error {
+	return utils.UDPRoutine("sFlow", s.decodeFlow, workers, addr, port, reuseport, s.Logger)
+}
+
+// decodeFlow does what utils.StateSFlow does, sniffing the sampled headers of
+// the decoded message before its flows are sent so that the transport finds
+// the hostnames of their client hellos.
+func (s *sFlowState) decodeFlow(msg interface{}) error {
+	pkt := msg.(utils.BaseMessage)
+	receivedAt := uint64(time.Now().UTC().Unix())
+	if pkt.SetTime {
+		receivedAt = uint64(pkt.RecvTime.UTC().Unix())
+	}
+
+	decoded, err := sflow.DecodeMessage(bytes.NewBuffer(pkt.Payload))
+	if err != nil {
+		utils.SFlowErrors.With(prometheus.Labels{"router": pkt.Src.String(), "error": "error_decoding"}).Inc()
+		return err
+	}
+
+	if packet, ok := decoded.(sflow.Packet); ok {
+		utils.SFlowStats.With(prometheus.Labels{"router": pkt.Src.String(), "agent": net.IP(packet.AgentIP).String(), "version": "5"}).Inc()
+		s.sniffSamples(packet)
+	}
+
+	messages, err := producer.ProcessMessageSFlowConfig(decoded, s.Config)
+	if err != nil {
+		return err
+	}
+
+	for _, aMessage := range messages {
+		aMessage.TimeReceived = receivedAt
+		aMessage.TimeFlowStart = receivedAt
+		aMessage.TimeFlowEnd = receivedAt
+
+		key, data, err := s.Format.Format(aMessage)
+		if err != nil {
+			s.logger.Error(err)
+			continue
+		}
+
+		if err := s.Transport.Send(key, data); err != nil {
+			s.logger.Error(err)
+		}
+	}
+
+	return nil
+}
+
+func (s *sFlowState) sniffSamples(packet sflow.Packet) {
+	for _, sample := range packet.Samples {
+		var records []sflow.FlowRecord
+		switch sampleConv := sample.(type) {
+		case sflow.FlowSample:
+			records = sampleConv.Records
+		case sflow.ExpandedFlowSample:
+			records = sampleConv.Records
+		}
+
+		for _, record := range records {
+			sampledHeader, ok := record.Data.(sflow.SampledHeader)
+			if !ok || sampledHeader.Protocol != ethernetHeaderProtocol {
+				continue
+			}
+
+			s.sniffSampledHeader(sampledHeader.HeaderData)
+		}
+	}
+}
+
+func (s *sFlowState) sniffSampledHeader(headerData []byte) {
+	packet := gopacket.NewPacket(headerData, layers.LayerTypeEthernet, gopacket.NoCopy)
+
+	for _, aSighting := range neighbors.FromPacket(packet) {
+		s.sightings <- aSighting
+	}
+
+	if aSighting := dhcp.FromPacket(packet); aSighting != nil {
+		s.sightings <- aSighting
+		return
+	}
+
+	clientHello, err := clienthello.FromPacket(packet)
+	if err != nil {
+
+		return
+	}
+
+	srcAddrIp := net.ParseIP(clientHello.SrcAddr)
+	dstAddrIp := net.ParseIP(clientHello.DstAddr)
+	if !isToConsider(s.cidr, s.exclusions, srcAddrIp, dstAddrIp) {
+
+		s.logger.Debugf("Ignoring client hello from %s to %s", srcAddrIp, dstAddrIp)
+		return
+	}
+
+	s.logger.Infof("[ %s:%d -> %s:%d ] | %s", clientHello.SrcAddr, clientHello.SrcPort, clientHello.DstAddr, clientHello.DstPort, clientHello.Hostname)
+	s.hostnames.Add(flowKey{
+		srcAddr: clientHello.SrcAddr,
+		dstAddr: clientHello.DstAddr,
+		srcPort: uint32(clientHello.SrcPort),
+		dstPort: uint32(clientHello.DstPort),
+		proto:   tcpProtocol,
+	}, clientHello.Hostname)
+}
@@ -1,13 +1,18 @@
 package handling
 
 import (
+	"auditor/healthiness"
 	"auditor/logger"
+	"auditor/metrics"
 	"auditor/model"
 	"context"
 	"crypto/sha256"
+	"encoding/binary"
 	"fmt"
 	"net"
+	"time"
 
+	lru "github.com/hashicorp/golang-lru"
 	flowmessage "github.com/netsampler/goflow2/pb"
 	"github.com/netsampler/goflow2/transport"
 	"google.golang.org/protobuf/proto"
@@ -19,12 +24,25 @@ const (
 	CxtKey key = iota
 )
 
-type promDriver struct {
-	cidr       *net.IPNet
-	exclusions []*net.IP
+const (
+	tcpProtocol = 6
+	udpProtocol = 17
+
+	// sniffedHostnames bounds the hostnames of the client hellos sniffed from
+	// sFlow samples waiting for their flows
+	sniffedHostnames = 64 * 1024
+)
 
-	c      chan *model.Action
-	logger *logger.Logger
+type promDriver struct {
+	cidr         *net.IPNet
+	exclusions   []*net.IP
+	deduplicator *deduplicator
+
+	c         chan *model.Action
+	sightings chan *model.Sighting
+	hostnames *lru.Cache
+	heartbeat *healthiness.Heartbeat
+	logger    *logger.Logger
 }
 
 func (d *promDriver) Prepare() error {
@@ -51,6 +69,7 @@ func (d *promDriver) Init(context context.Context) error {
 	d.logger = logger
 	d.cidr = nflowConf.Cidr
 	d.exclusions = nflowConf.Exclusions
+	d.deduplicator = newDeduplicator(*nflowConf.DedupWindow, nflowConf.ExporterPriority)
 
 	return nil
 }
@@ -62,54 +81,158 @@ func (d *promDriver) Send(key, data []byte) error {
 	if err != nil {
 		return fmt.Errorf("error unmarshalling message: %v", err)
 	}
+	d.heartbeat.Beat()
+	metrics.FlowsReceived.Inc()
 
 	hash := sha256.Sum256(data)
 	d.logger.Log.Infof("Parsing message: %x", hash[:])
 
-	srcAddrIpv4 := net.IPv4(message.SrcAddr[0], message.SrcAddr[1], message.SrcAddr[2], message.SrcAddr[3])
-	dstAddrIpv4 := net.IPv4(message.DstAddr[0], message.DstAddr[1], message.DstAddr[2], message.DstAddr[3])
+	if !isIpAddress(message.SrcAddr) || !isIpAddress(message.DstAddr) {
+		d.logger.Log.Debugf("Ignoring %s message without ip addresses", message.Type)
+		metrics.FlowsFiltered.WithLabelValues("no-ip").Inc()
+		return nil
+	}
 
-	isSrcToConsider := d.cidr.Contains(srcAddrIpv4)
-	isDstToConsider := d.cidr.Contains(dstAddrIpv4)
+	flow := flowKey{
+		srcAddr: net.IP(message.SrcAddr).String(),
+		dstAddr: net.IP(message.DstAddr).String(),
+		srcPort: message.SrcPort,
+		dstPort: message.DstPort,
+		proto:   message.Proto,
+	}
+	exporter := net.IP(message.SamplerAddress).String()
+	seenAt := time.Unix(int64(message.TimeReceived), 0)
+	var hostname string
+	if value, ok := d.hostnames.Get(flow); ok {
+		hostname = value.(string)
+	}
+	if d.deduplicator.observe(flow, exporter, seenAt, func() { d.handle(message, hostname) }) {
+		d.logger.Log.Debugf("Ignoring message already reported by another exporter")
+		metrics.FlowsFiltered.WithLabelValues("duplicate").Inc()
+	}
 
-	for _, anExclusion := range d.exclusions {
-		if anExclusion.Equal(srcAddrIpv4) {
-			isSrcToConsider = false
-		}
-		if anExclusion.Equal(dstAddrIpv4) {
-			isDstToConsider = false
+	return nil
+}
+
+// handle sends the action of the flow, with the hostname of its client hello
+// when one was sniffed.
+func (d *promDriver) handle(message *flowmessage.FlowMessage, hostname string) {
+	srcAddrIp := net.IP(message.SrcAddr)
+	dstAddrIp := net.IP(message.DstAddr)
+
+	if message.SrcMac != 0 && (srcAddrIp.IsPrivate() || srcAddrIp.IsLinkLocalUnicast()) {
+		d.sightings <- &model.Sighting{
+			Mac: macFrom(message.SrcMac).String(),
+			Ip:  srcAddrIp.String(),
 		}
 	}
 
-	if isSrcToConsider || isDstToConsider {
-		srcAddr := srcAddrIpv4.String()
-		dstAddr := dstAddrIpv4.String()
+	if isToConsider(d.cidr, d.exclusions, srcAddrIp, dstAddrIp) {
+		srcAddr := srcAddrIp.String()
+		dstAddr := dstAddrIp.String()
+		exporter := net.IP(message.SamplerAddress).String()
+		seenAt := flowTime(message)
+
+		action := &model.Action{
+			SrcAddr:  &srcAddr,
+			DstAddr:  &dstAddr,
+			Exporter: &exporter,
+			Time:     &seenAt,
+		}
+
+		if message.Proto == tcpProtocol || message.Proto == udpProtocol {
+			srcPort := uint16(message.SrcPort)
+			dstPort := uint16(message.DstPort)
+			action.SrcPort = &srcPort
+			action.DstPort = &dstPort
+		}
 
-		d.c <- &model.Action{
-			SrcAddr: &srcAddr,
-			DstAddr: &dstAddr,
+		if hostname != "" {
+			action.Hostname = &hostname
 		}
+
+		d.c <- action
 	} else {
 
-		d.logger.Log.Debugf("Ignoring message from %s to %s", srcAddrIpv4, dstAddrIpv4)
+		d.logger.Log.Debugf("Ignoring message from %s to %s", srcAddrIp, dstAddrIp)
+		metrics.FlowsFiltered.WithLabelValues("excluded").Inc()
 	}
-
-	return nil
 }
 
 func (d *promDriver) Close(context.Context) error {
 	d.logger.Log.Info("Closing to channel driver")
+	d.deduplicator.dispose()
 	return nil
 }
 
 var d promDriver = promDriver{
-	c: make(chan *model.Action),
+	c:         make(chan *model.Action),
+	sightings: make(chan *model.Sighting),
+	heartbeat: healthiness.NewHeartbeat("flow collector"),
 }
 
 func init() {
+	hostnames, err := lru.New(sniffedHostnames)
+	if err != nil {
+		panic(err)
+	}
+	d.hostnames = hostnames
+
 	transport.RegisterTransportDriver("to-channel", &d)
 }
 
+func isToConsider(cidr *net.IPNet, exclusions []*net.IP, srcAddr, dstAddr net.IP) bool {
+	isSrcToConsider := cidr.Contains(srcAddr)
+	isDstToConsider := cidr.Contains(dstAddr)
+
+	for _, anExclusion := range exclusions {
+		if anExclusion.Equal(srcAddr) {
+			isSrcToConsider = false
+		}
+		if anExclusion.Equal(dstAddr) {
+			isDstToConsider = false
+		}
+	}
+
+	return isSrcToConsider || isDstToConsider
+}
+
+func macFrom(value uint64) net.HardwareAddr {
+	mac := make(net.HardwareAddr, 8)
+	binary.BigEndian.PutUint64(mac, value)
+	return mac[2:]
+}
+
+// flowTime is when the flow started, sFlow samples only carry the time they
+// were received.
+func flowTime(message *flowmessage.FlowMessage) time.Time {
+	if message.TimeFlowStart > 0 {
+		return time.Unix(int64(message.TimeFlowStart), 0)
+	}
+
+	if message.TimeReceived > 0 {
+		return time.Unix(int64(message.TimeReceived), 0)
+	}
+
+	return time.Now()
+}
+
+func isIpAddress(addr []byte) bool {
+	return len(addr) == net.IPv4len || len(addr) == net.IPv6len
+}
+
 func promDriverChannel() chan *model.Action {
 	return d.c
 }
+
+func promDriverSightings() chan *model.Sighting {
+	return d.sightings
+}
+
+func promDriverHostnames() *lru.Cache {
+	return d.hostnames
+}
+
+func promDriverHeartbeat() *healthiness.Heartbeat {
+	return d.heartbeat
+}
@@ -1,23 +1,157 @@
 package healthiness
 
 import (
+	"encoding/json"
 	"net/http"
+	"sort"
+	"sync"
+	"time"
 
 	logFacility "auditor/logger"
+	"auditor/serving"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+type Probe uint8
+
+const (
+	// Liveness checks fail only when a restart is the way out, like the
+	// database being closed or the capture loop being dead.
+	Liveness Probe = iota
+	// Readiness checks include everything liveness does plus degraded states,
+	// like no traffic received recently or unreachable providers.
+	Readiness
 )
 
-func dumbHandler(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
+type Check func() error
+
+type ComponentStatus struct {
+	Healthy bool   `json:"healthy"`
+	Detail  string `json:"detail,omitempty"`
 }
 
-func Healthiness(logger *logFacility.Logger) {
-	router := http.NewServeMux()
+type Status struct {
+	Healthy    bool                        `json:"healthy"`
+	Components map[string]*ComponentStatus `json:"components"`
+}
+
+type HealthinessConfiguration struct {
+	Serving    *serving.ServingConfiguration
+	MaxSilence *time.Duration
+}
+
+type registeredCheck struct {
+	probe Probe
+	check Check
+	// informational checks are reported without failing the probe
+	informational bool
+}
+
+type Healthiness struct {
+	logger        *logFacility.Logger
+	configuration *HealthinessConfiguration
 
-	router.HandleFunc("/live", dumbHandler)
-	router.HandleFunc("/ready", dumbHandler)
-	logger.Log.Info("Starting healthiness metrics server on port 8080")
-	err := http.ListenAndServe(":8080", router)
-	if err != nil {
-		panic(err)
+	mutex  *sync.RWMutex
+	checks map[string]*registeredCheck
+}
+
+func New(logger *logFacility.Logger, healthinessConf *HealthinessConfiguration) *Healthiness {
+	return &Healthiness{
+		logger:        logger,
+		configuration: healthinessConf,
+
+		mutex:  &sync.RWMutex{},
+		checks: make(map[string]*registeredCheck),
 	}
 }
+
+func (h *Healthiness) MaxSilence() time.Duration {
+	return *h.configuration.MaxSilence
+}
+
+func (h *Healthiness) Register(name string, probe Probe, check Check) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.checks[name] = &registeredCheck{
+		probe: probe,
+		check: check,
+	}
+}
+
+// RegisterInformational reports the check in the status of the probe, and the
+// ones including it, without failing them.
+func (h *Healthiness) RegisterInformational(name string, probe Probe, check Check) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.checks[name] = &registeredCheck{
+		probe:         probe,
+		check:         check,
+		informational: true,
+	}
+}
+
+func (h *Healthiness) Status(probe Probe) *Status {
+	h.mutex.RLock()
+	names := make([]string, 0, len(h.checks))
+	for name, aCheck := range h.checks {
+		if aCheck.probe <= probe {
+			names = append(names, name)
+		}
+	}
+	h.mutex.RUnlock()
+	sort.Strings(names)
+
+	toReturn := &Status{
+		Healthy:    true,
+		Components: make(map[string]*ComponentStatus, len(names)),
+	}
+
+	for _, name := range names {
+		h.mutex.RLock()
+		aCheck := h.checks[name]
+		h.mutex.RUnlock()
+
+		componentStatus := &ComponentStatus{
+			Healthy: true,
+		}
+		if err := aCheck.check(); err != nil {
+			componentStatus.Healthy = false
+			componentStatus.Detail = err.Error()
+			toReturn.Healthy = toReturn.Healthy && aCheck.informational
+		}
+
+		toReturn.Components[name] = componentStatus
+	}
+
+	return toReturn
+}
+
+func (h *Healthiness) handler(probe Probe) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := h.Status(probe)
+
+		w.Header().Set("Content-Type", "application/json")
+		if status.Healthy {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			h.logger.Log.Warn(err)
+		}
+	}
+}
+
+func (h *Healthiness) Serve() error {
+	router := http.NewServeMux()
+
+	router.HandleFunc("/live", h.handler(Liveness))
+	router.HandleFunc("/ready", h.handler(Readiness))
+	router.Handle("/metrics", promhttp.Handler())
+
+	return serving.Serve(h.logger, "healthiness", h.configuration.Serving, router)
+}
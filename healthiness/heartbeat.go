@@ -0,0 +1,59 @@
+package healthiness
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Heartbeat tracks a loop that is expected to keep receiving data, like the
+// packet capture or the flow collector.
+type Heartbeat struct {
+	name     string
+	running  *atomic.Bool
+	lastBeat *atomic.Int64
+}
+
+func NewHeartbeat(name string) *Heartbeat {
+	return &Heartbeat{
+		name:     name,
+		running:  &atomic.Bool{},
+		lastBeat: &atomic.Int64{},
+	}
+}
+
+func (h *Heartbeat) Started() {
+	h.lastBeat.Store(time.Now().UnixNano())
+	h.running.Store(true)
+}
+
+func (h *Heartbeat) Stopped() {
+	h.running.Store(false)
+}
+
+func (h *Heartbeat) Beat() {
+	h.lastBeat.Store(time.Now().UnixNano())
+}
+
+func (h *Heartbeat) Running() error {
+	if !h.running.Load() {
+		return fmt.Errorf("%s is not running", h.name)
+	}
+
+	return nil
+}
+
+func (h *Heartbeat) Recent(maxSilence time.Duration) Check {
+	return func() error {
+		if err := h.Running(); err != nil {
+			return err
+		}
+
+		silence := time.Since(time.Unix(0, h.lastBeat.Load()))
+		if silence > maxSilence {
+			return fmt.Errorf("%s received nothing in the last %s", h.name, silence.Truncate(time.Second))
+		}
+
+		return nil
+	}
+}
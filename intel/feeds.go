@@ -0,0 +1,116 @@
+package intel
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	fetchTimeout = time.Minute
+	maxFeedSize  = 256 << 20
+)
+
+// FeedDefinition is how feeds are written in the feeds file, a json array of
+// them. Source is an http(s) url or a local path, Format is one of text, csv,
+// stix, abusech or misp.
+type FeedDefinition struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	Format string `json:"format"`
+	// Column of the indicators in csv feeds, the first one by default
+	Column int `json:"column,omitempty"`
+	// Context added to every indicator of the feed
+	Context string `json:"context,omitempty"`
+}
+
+type parser func(content []byte, definition *FeedDefinition) ([]*indicator, error)
+
+var parsers = map[string]parser{
+	"text":    parseText,
+	"csv":     parseCsv,
+	"stix":    parseStix,
+	"abusech": parseAbuseCh,
+	"misp":    parseMisp,
+}
+
+func readFeeds(file string) ([]*FeedDefinition, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	definitions := make([]*FeedDefinition, 0)
+	if err := json.Unmarshal(content, &definitions); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	names := make(map[string]bool, len(definitions))
+	for _, aDefinition := range definitions {
+		if names[aDefinition.Name] {
+			return nil, fmt.Errorf("%s: feed %s is defined twice", file, aDefinition.Name)
+		}
+		names[aDefinition.Name] = true
+
+		if err := validate(aDefinition); err != nil {
+			return nil, fmt.Errorf("%s: feed %s: %w", file, aDefinition.Name, err)
+		}
+	}
+
+	return definitions, nil
+}
+
+func validate(definition *FeedDefinition) error {
+	if strings.TrimSpace(definition.Name) == "" {
+		return fmt.Errorf("name is mandatory")
+	}
+
+	if strings.TrimSpace(definition.Source) == "" {
+		return fmt.Errorf("source is mandatory")
+	}
+
+	if definition.Format == "" {
+		definition.Format = "text"
+	}
+	definition.Format = strings.ToLower(definition.Format)
+	if _, ok := parsers[definition.Format]; !ok {
+		return fmt.Errorf("format %s does not exist, use one of text, csv, stix, abusech, misp", definition.Format)
+	}
+
+	if definition.Column < 0 {
+		return fmt.Errorf("column cannot be negative")
+	}
+
+	return nil
+}
+
+func load(client *http.Client, definition *FeedDefinition) ([]*indicator, error) {
+	content, err := fetch(client, definition.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	return parsers[definition.Format](content, definition)
+}
+
+func fetch(client *http.Client, source string) ([]byte, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.ReadFile(source)
+	}
+
+	response, err := client.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s answered %s", source, response.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(response.Body, maxFeedSize))
+}
@@ -0,0 +1,414 @@
+package intel
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	ipIndicator     = "ip"
+	cidrIndicator   = "cidr"
+	domainIndicator = "domain"
+	urlIndicator    = "url"
+)
+
+// sharedHosters serve the files of anyone, a url of a payload on them says
+// nothing about their other traffic.
+var sharedHosters = []string{
+	"github.com", "githubusercontent.com", "gitlab.com", "bitbucket.org",
+	"googleapis.com", "googleusercontent.com", "drive.google.com", "docs.google.com",
+	"dropbox.com", "dropboxusercontent.com", "onedrive.live.com", "1drv.ms", "sharepoint.com",
+	"discordapp.com", "discordapp.net", "discord.com", "telegram.org",
+	"amazonaws.com", "cloudfront.net", "blob.core.windows.net", "azureedge.net", "web.core.windows.net",
+	"pastebin.com", "paste.ee", "transfer.sh", "mediafire.com", "mega.nz", "archive.org",
+}
+
+type indicator struct {
+	value   string
+	kind    string
+	context string
+	// host is the hostname of url indicators, the only one they match
+	host string
+}
+
+// indicatorFrom accepts ips, networks, domains and urls or host:port pairs
+// around them, it returns nil for anything else. Urls on ips are kept as ips,
+// the others as urls matching their host only, unless a shared hoster serves
+// them.
+func indicatorFrom(raw string, context string) *indicator {
+	value := strings.ToLower(strings.Trim(strings.TrimSpace(raw), "\"'"))
+	if index := strings.Index(value, "://"); index >= 0 {
+		return urlIndicatorFrom(strings.Trim(strings.TrimSpace(raw), "\"'"), value[index+3:], context)
+	}
+
+	if ip, network, err := net.ParseCIDR(value); err == nil {
+		ones, bits := network.Mask.Size()
+		if ones == bits {
+			return &indicator{value: ip.String(), kind: ipIndicator, context: context}
+		}
+		return &indicator{value: network.String(), kind: cidrIndicator, context: context}
+	}
+
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	value = strings.Trim(value, "[]")
+	if ip := net.ParseIP(value); ip != nil {
+		return &indicator{value: ip.String(), kind: ipIndicator, context: context}
+	}
+
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "*."), ".")
+	if !isDomain(value) {
+		return nil
+	}
+
+	return &indicator{value: value, kind: domainIndicator, context: context}
+}
+
+func urlIndicatorFrom(url string, value string, context string) *indicator {
+	if end := strings.IndexAny(value, "/?#"); end >= 0 {
+		value = value[:end]
+	}
+	if at := strings.LastIndex(value, "@"); at >= 0 {
+		value = value[at+1:]
+	}
+
+	toReturn := indicatorFrom(value, context)
+	if toReturn == nil || toReturn.kind != domainIndicator {
+		return toReturn
+	}
+
+	if isSharedHoster(toReturn.value) {
+		return nil
+	}
+
+	return &indicator{value: url, kind: urlIndicator, context: context, host: toReturn.value}
+}
+
+func isSharedHoster(host string) bool {
+	for _, aHoster := range sharedHosters {
+		if host == aHoster || strings.HasSuffix(host, "."+aHoster) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isDomain(value string) bool {
+	if !strings.Contains(value, ".") || len(value) > 253 {
+		return false
+	}
+
+	for _, aRune := range value {
+		if (aRune < 'a' || aRune > 'z') && (aRune < '0' || aRune > '9') && aRune != '.' && aRune != '-' && aRune != '_' {
+			return false
+		}
+	}
+
+	return !strings.HasPrefix(value, ".") && !strings.Contains(value, "..")
+}
+
+// parseText reads an indicator per line, hosts files are accepted too.
+func parseText(content []byte, definition *FeedDefinition) ([]*indicator, error) {
+	toReturn := make([]*indicator, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if index := strings.IndexAny(line, "#;"); index >= 0 {
+			line = line[:index]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		value := fields[0]
+		if len(fields) > 1 && (fields[0] == "0.0.0.0" || fields[0] == "127.0.0.1" || fields[0] == "::") {
+			value = fields[1]
+		}
+
+		if anIndicator := indicatorFrom(value, definition.Context); anIndicator != nil {
+			toReturn = append(toReturn, anIndicator)
+		}
+	}
+
+	return toReturn, scanner.Err()
+}
+
+// parseCsv reads the indicators in the configured column, rows where it is
+// not an indicator, like headers, are skipped.
+func parseCsv(content []byte, definition *FeedDefinition) ([]*indicator, error) {
+	toReturn := make([]*indicator, 0)
+	err := forEachRecord(content, func(record []string) {
+		if definition.Column >= len(record) {
+			return
+		}
+
+		if anIndicator := indicatorFrom(record[definition.Column], definition.Context); anIndicator != nil {
+			toReturn = append(toReturn, anIndicator)
+		}
+	})
+
+	return toReturn, err
+}
+
+func forEachRecord(content []byte, consumer func(record []string)) error {
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		consumer(record)
+	}
+}
+
+var stixPattern = regexp.MustCompile(`(ipv4-addr|ipv6-addr|domain-name|url):value\s*=\s*'((?:[^'\\]|\\.)*)'`)
+
+type stixObject struct {
+	Type           string    `json:"type"`
+	Name           string    `json:"name"`
+	Description    string    `json:"description"`
+	Pattern        string    `json:"pattern"`
+	Value          string    `json:"value"`
+	Labels         []string  `json:"labels"`
+	IndicatorTypes []string  `json:"indicator_types"`
+	Revoked        bool      `json:"revoked"`
+	ValidUntil     time.Time `json:"valid_until"`
+}
+
+// parseStix reads the indicators patterns and the ip, domain and url
+// observables of a STIX 2.1 bundle.
+func parseStix(content []byte, definition *FeedDefinition) ([]*indicator, error) {
+	bundle := struct {
+		Objects []*stixObject `json:"objects"`
+	}{}
+	if err := json.Unmarshal(content, &bundle); err != nil {
+		return nil, err
+	}
+
+	toReturn := make([]*indicator, 0)
+	for _, anObject := range bundle.Objects {
+		switch anObject.Type {
+		case "indicator":
+			if anObject.Revoked || (!anObject.ValidUntil.IsZero() && anObject.ValidUntil.Before(time.Now())) {
+				continue
+			}
+
+			context := joinContext(definition.Context, anObject.Name, strings.Join(append(anObject.IndicatorTypes, anObject.Labels...), ","))
+			for _, aMatch := range stixPattern.FindAllStringSubmatch(anObject.Pattern, -1) {
+				if anIndicator := indicatorFrom(strings.ReplaceAll(aMatch[2], `\'`, "'"), context); anIndicator != nil {
+					toReturn = append(toReturn, anIndicator)
+				}
+			}
+		case "ipv4-addr", "ipv6-addr", "domain-name", "url":
+			if anIndicator := indicatorFrom(anObject.Value, definition.Context); anIndicator != nil {
+				toReturn = append(toReturn, anIndicator)
+			}
+		}
+	}
+
+	return toReturn, nil
+}
+
+type abuseChEntry struct {
+	IpAddress        string `json:"ip_address"`
+	Port             any    `json:"port"`
+	Malware          string `json:"malware"`
+	IocValue         string `json:"ioc_value"`
+	MalwarePrintable string `json:"malware_printable"`
+	ThreatType       string `json:"threat_type"`
+}
+
+func (e *abuseChEntry) indicator(feedContext string) *indicator {
+	if e.IpAddress != "" {
+		return indicatorFrom(e.IpAddress, joinContext(feedContext, e.Malware))
+	}
+
+	return indicatorFrom(e.IocValue, joinContext(feedContext, e.MalwarePrintable, e.ThreatType))
+}
+
+// parseAbuseCh reads the json exports of Feodo Tracker and ThreatFox, and the
+// csv or plain ones of every abuse.ch tracker taking the first indicator of
+// each row.
+func parseAbuseCh(content []byte, definition *FeedDefinition) ([]*indicator, error) {
+	trimmed := bytes.TrimSpace(content)
+	entries := make([]*abuseChEntry, 0)
+
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		if err := json.Unmarshal(trimmed, &entries); err != nil {
+			return nil, err
+		}
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		response := struct {
+			Data []*abuseChEntry `json:"data"`
+		}{}
+		if err := json.Unmarshal(trimmed, &response); err == nil && len(response.Data) > 0 {
+			entries = response.Data
+			break
+		}
+
+		export := make(map[string][]*abuseChEntry)
+		if err := json.Unmarshal(trimmed, &export); err != nil {
+			return nil, err
+		}
+		for _, someEntries := range export {
+			entries = append(entries, someEntries...)
+		}
+	default:
+		toReturn := make([]*indicator, 0)
+		err := forEachRecord(content, func(record []string) {
+			for _, aField := range record {
+				if anIndicator := indicatorFrom(aField, definition.Context); anIndicator != nil {
+					toReturn = append(toReturn, anIndicator)
+					return
+				}
+			}
+		})
+
+		return toReturn, err
+	}
+
+	toReturn := make([]*indicator, 0, len(entries))
+	for _, anEntry := range entries {
+		if anIndicator := anEntry.indicator(definition.Context); anIndicator != nil {
+			toReturn = append(toReturn, anIndicator)
+		}
+	}
+
+	return toReturn, nil
+}
+
+type mispAttribute struct {
+	Type    string `json:"type"`
+	Value   string `json:"value"`
+	Comment string `json:"comment"`
+}
+
+type mispEvent struct {
+	Info      string           `json:"info"`
+	Attribute []*mispAttribute `json:"Attribute"`
+	Object    []struct {
+		Attribute []*mispAttribute `json:"Attribute"`
+	} `json:"Object"`
+}
+
+type mispWrapper struct {
+	Event *mispEvent `json:"Event"`
+}
+
+// parseMisp reads the ip, domain, hostname and url attributes of MISP json
+// exports, either events or restSearch responses.
+func parseMisp(content []byte, definition *FeedDefinition) ([]*indicator, error) {
+	trimmed := bytes.TrimSpace(content)
+	events := make([]*mispWrapper, 0)
+	attributes := make([]*mispAttribute, 0)
+
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		if err := json.Unmarshal(trimmed, &events); err != nil {
+			return nil, err
+		}
+	} else {
+		export := struct {
+			Event    *mispEvent      `json:"Event"`
+			Response json.RawMessage `json:"response"`
+		}{}
+		if err := json.Unmarshal(trimmed, &export); err != nil {
+			return nil, err
+		}
+
+		if export.Event != nil {
+			events = append(events, &mispWrapper{Event: export.Event})
+		}
+
+		if bytes.HasPrefix(bytes.TrimSpace(export.Response), []byte("[")) {
+			if err := json.Unmarshal(export.Response, &events); err != nil {
+				return nil, err
+			}
+		} else if len(export.Response) > 0 {
+			response := struct {
+				Attribute []*mispAttribute `json:"Attribute"`
+			}{}
+			if err := json.Unmarshal(export.Response, &response); err != nil {
+				return nil, err
+			}
+			attributes = append(attributes, response.Attribute...)
+		}
+	}
+
+	toReturn := make([]*indicator, 0)
+	add := func(someAttributes []*mispAttribute, info string) {
+		for _, anAttribute := range someAttributes {
+			toReturn = append(toReturn, mispIndicators(anAttribute, joinContext(definition.Context, info, anAttribute.Comment))...)
+		}
+	}
+
+	add(attributes, "")
+	for _, anEvent := range events {
+		if anEvent.Event == nil {
+			continue
+		}
+
+		add(anEvent.Event.Attribute, anEvent.Event.Info)
+		for _, anObject := range anEvent.Event.Object {
+			add(anObject.Attribute, anEvent.Event.Info)
+		}
+	}
+
+	return toReturn, nil
+}
+
+// mispIndicators splits composite attributes, like domain|ip, keeping their
+// ip and domain parts.
+func mispIndicators(attribute *mispAttribute, context string) []*indicator {
+	types := strings.Split(attribute.Type, "|")
+	values := strings.Split(attribute.Value, "|")
+
+	toReturn := make([]*indicator, 0, len(types))
+	for index, aType := range types {
+		if index >= len(values) {
+			break
+		}
+
+		switch aType {
+		case "ip-src", "ip-dst", "ip", "domain", "hostname", "url":
+			if anIndicator := indicatorFrom(values[index], context); anIndicator != nil {
+				toReturn = append(toReturn, anIndicator)
+			}
+		}
+	}
+
+	return toReturn
+}
+
+func joinContext(values ...string) string {
+	toReturn := make([]string, 0, len(values))
+	for _, aValue := range values {
+		if aValue = strings.TrimSpace(aValue); aValue != "" {
+			toReturn = append(toReturn, aValue)
+		}
+	}
+
+	return strings.Join(toReturn, "; ")
+}
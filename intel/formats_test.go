@@ -0,0 +1,162 @@
+package intel
+
+import (
+	"reflect"
+	"testing"
+)
+
+func kinds(indicators []*indicator) []string {
+	toReturn := make([]string, 0, len(indicators))
+	for _, anIndicator := range indicators {
+		value := anIndicator.kind + ":" + anIndicator.value
+		if anIndicator.host != "" {
+			value += "@" + anIndicator.host
+		}
+		toReturn = append(toReturn, value)
+	}
+
+	return toReturn
+}
+
+func TestIndicatorFrom(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"1.2.3.4", "ip:1.2.3.4"},
+		{" '1.2.3.4' ", "ip:1.2.3.4"},
+		{"1.2.3.4:8080", "ip:1.2.3.4"},
+		{"[2001:db8::1]:443", "ip:2001:db8::1"},
+		{"10.0.0.0/8", "cidr:10.0.0.0/8"},
+		{"10.0.0.1/32", "ip:10.0.0.1"},
+		{"Evil.Example.COM.", "domain:evil.example.com"},
+		{"*.evil.example.com", "domain:evil.example.com"},
+		{"http://1.2.3.4/bins/mips", "ip:1.2.3.4"},
+		{"https://Evil.example.com/Payload.exe", "url:https://Evil.example.com/Payload.exe@evil.example.com"},
+		{"http://user@evil.example.com:8080/x?y#z", "url:http://user@evil.example.com:8080/x?y#z@evil.example.com"},
+		{"https://github.com/x/y/raw/payload", ""},
+		{"https://raw.githubusercontent.com/x/y/main/payload", ""},
+		{"https://cdn.discordapp.com/attachments/1/2/payload.exe", ""},
+		{"localhost", ""},
+		{"not a domain.com", ""},
+		{"", ""},
+	}
+
+	for _, aTest := range tests {
+		got := ""
+		if anIndicator := indicatorFrom(aTest.raw, ""); anIndicator != nil {
+			got = kinds([]*indicator{anIndicator})[0]
+		}
+
+		if got != aTest.want {
+			t.Errorf("indicatorFrom(%q) = %q, want %q", aTest.raw, got, aTest.want)
+		}
+	}
+}
+
+func TestParsers(t *testing.T) {
+	tests := []struct {
+		name       string
+		parse      parser
+		definition *FeedDefinition
+		content    string
+		want       []string
+	}{
+		{
+			name:       "text",
+			parse:      parseText,
+			definition: &FeedDefinition{},
+			content: `# comment
+1.2.3.4
+0.0.0.0 ads.example.com # hosts line
+127.0.0.1 localhost
+5.6.7.0/24 ; trailing comment
+
+https://evil.example.com/x
+`,
+			want: []string{"ip:1.2.3.4", "domain:ads.example.com", "cidr:5.6.7.0/24", "url:https://evil.example.com/x@evil.example.com"},
+		},
+		{
+			name:       "csv",
+			parse:      parseCsv,
+			definition: &FeedDefinition{Column: 2},
+			content: `# id,date,url,status
+"1","2024-01-01 00:00:00","http://evil.example.com/a.sh","online"
+"2","2024-01-01 00:00:00","https://github.com/x/y/raw/payload","online"
+"3","2024-01-01 00:00:00","http://9.8.7.6:81/i","offline"
+id,date,url,status
+"4","2024-01-01"
+`,
+			want: []string{"url:http://evil.example.com/a.sh@evil.example.com", "ip:9.8.7.6"},
+		},
+		{
+			name:       "stix",
+			parse:      parseStix,
+			definition: &FeedDefinition{},
+			content: `{"type":"bundle","objects":[
+{"type":"indicator","pattern":"[ipv4-addr:value = '1.2.3.4'] OR [domain-name:value = 'evil.example.com']"},
+{"type":"indicator","pattern":"[url:value = 'http://evil.example.org/x']"},
+{"type":"indicator","revoked":true,"pattern":"[ipv4-addr:value = '4.4.4.4']"},
+{"type":"indicator","valid_until":"2000-01-01T00:00:00Z","pattern":"[ipv4-addr:value = '5.5.5.5']"},
+{"type":"ipv6-addr","value":"2001:db8::1"},
+{"type":"malware","name":"ignored"}
+]}`,
+			want: []string{"ip:1.2.3.4", "domain:evil.example.com", "url:http://evil.example.org/x@evil.example.org", "ip:2001:db8::1"},
+		},
+		{
+			name:       "abusech feodo json",
+			parse:      parseAbuseCh,
+			definition: &FeedDefinition{},
+			content:    `[{"ip_address":"1.2.3.4","port":443,"malware":"QakBot"},{"ip_address":"bad"}]`,
+			want:       []string{"ip:1.2.3.4"},
+		},
+		{
+			name:       "abusech threatfox json",
+			parse:      parseAbuseCh,
+			definition: &FeedDefinition{},
+			content: `{"1":[{"ioc_value":"evil.example.com","malware_printable":"Cobalt Strike","threat_type":"botnet_cc"}],
+"2":[{"ioc_value":"https://github.com/x/y/raw/payload","malware_printable":"Lumma","threat_type":"payload_delivery"}],
+"3":[{"ioc_value":"5.6.7.8:4444","malware_printable":"AsyncRAT","threat_type":"botnet_cc"}]}`,
+			want: []string{"domain:evil.example.com", "ip:5.6.7.8"},
+		},
+		{
+			name:       "abusech csv",
+			parse:      parseAbuseCh,
+			definition: &FeedDefinition{},
+			content: `# first_seen_utc,dst_ip,dst_port
+"2024-01-01 00:00:00","1.2.3.4","443"
+`,
+			want: []string{"ip:1.2.3.4"},
+		},
+		{
+			name:       "misp event",
+			parse:      parseMisp,
+			definition: &FeedDefinition{},
+			content: `{"Event":{"info":"campaign","Attribute":[
+{"type":"ip-dst","value":"1.2.3.4"},
+{"type":"domain|ip","value":"evil.example.com|5.6.7.8"},
+{"type":"md5","value":"d41d8cd98f00b204e9800998ecf8427e"}
+],"Object":[{"Attribute":[{"type":"url","value":"http://evil.example.org/x"}]}]}}`,
+			want: []string{"ip:1.2.3.4", "domain:evil.example.com", "ip:5.6.7.8", "url:http://evil.example.org/x@evil.example.org"},
+		},
+		{
+			name:       "misp rest search",
+			parse:      parseMisp,
+			definition: &FeedDefinition{},
+			content:    `{"response":{"Attribute":[{"type":"hostname","value":"c2.example.com"},{"type":"url","value":"https://drive.google.com/uc?id=1"}]}}`,
+			want:       []string{"domain:c2.example.com"},
+		},
+	}
+
+	for _, aTest := range tests {
+		indicators, err := aTest.parse([]byte(aTest.content), aTest.definition)
+		if err != nil {
+			t.Errorf("%s: %v", aTest.name, err)
+			continue
+		}
+
+		if got := kinds(indicators); !reflect.DeepEqual(got, aTest.want) {
+			t.Errorf("%s: got %v, want %v", aTest.name, got, aTest.want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package intel
+
+import (
+	logFacility "auditor/logger"
+	"auditor/model"
+	"net"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type IntelConfiguration struct {
+	FeedsFile       *string
+	RefreshInterval *time.Duration
+}
+
+// Intel loads the indicators of the threat intelligence feeds, refreshes them
+// on a schedule and tags the stored meta of the ips and hostnames matching
+// them.
+type Intel struct {
+	logger *logFacility.Logger
+	model  *model.Model
+	client *http.Client
+	feeds  []*FeedDefinition
+
+	mutex *sync.RWMutex
+	// the last indicators loaded per feed, kept when a refresh fails
+	indicators map[string][]*indicator
+	index      *index
+
+	ticker      *time.Ticker
+	tickersDone chan bool
+}
+
+type network struct {
+	network *net.IPNet
+	match   model.ThreatIntelMatch
+}
+
+type index struct {
+	ips      map[string][]model.ThreatIntelMatch
+	networks []*network
+	domains  map[string][]model.ThreatIntelMatch
+	hosts    map[string][]model.ThreatIntelMatch
+}
+
+func New(logger *logFacility.Logger, model *model.Model, intelConf *IntelConfiguration) (*Intel, error) {
+	toReturn := &Intel{
+		logger:      logger,
+		model:       model,
+		client:      &http.Client{Timeout: fetchTimeout},
+		feeds:       make([]*FeedDefinition, 0),
+		mutex:       &sync.RWMutex{},
+		indicators:  make(map[string][]*indicator),
+		index:       indexFrom(nil),
+		ticker:      time.NewTicker(*intelConf.RefreshInterval),
+		tickersDone: make(chan bool),
+	}
+
+	if intelConf.FeedsFile == nil || strings.EqualFold(*intelConf.FeedsFile, "") {
+		logger.Log.Info("No threat intelligence feeds configured")
+		return toReturn, nil
+	}
+
+	feeds, err := readFeeds(*intelConf.FeedsFile)
+	if err != nil {
+		return nil, err
+	}
+	toReturn.feeds = feeds
+	logger.Log.Infof("Configured %d threat intelligence feeds", len(feeds))
+
+	return toReturn, nil
+}
+
+func (i *Intel) Run() {
+	if len(i.feeds) == 0 {
+		return
+	}
+
+	i.refresh()
+	for {
+		select {
+		case <-i.tickersDone:
+			return
+		case <-i.ticker.C:
+			i.refresh()
+		}
+	}
+}
+
+func (i *Intel) Dispose() {
+	i.ticker.Stop()
+	close(i.tickersDone)
+}
+
+// Match returns the indicators matching the ip or its hostnames, domains
+// match their subdomains too, urls their exact host only.
+func (i *Intel) Match(ip string, hostnames []string) []model.ThreatIntelMatch {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	toReturn := make([]model.ThreatIntelMatch, 0)
+	if parsedIp := net.ParseIP(ip); parsedIp != nil {
+		toReturn = append(toReturn, i.index.ips[parsedIp.String()]...)
+		for _, aNetwork := range i.index.networks {
+			if aNetwork.network.Contains(parsedIp) {
+				toReturn = append(toReturn, aNetwork.match)
+			}
+		}
+	}
+
+	for _, aHostname := range hostnames {
+		domain := strings.TrimSuffix(strings.ToLower(aHostname), ".")
+		toReturn = append(toReturn, i.index.hosts[domain]...)
+		for domain != "" {
+			toReturn = append(toReturn, i.index.domains[domain]...)
+
+			dot := strings.Index(domain, ".")
+			if dot < 0 {
+				break
+			}
+			domain = domain[dot+1:]
+		}
+	}
+
+	return deduplicate(toReturn)
+}
+
+func (i *Intel) refresh() {
+	for _, aFeed := range i.feeds {
+		indicators, err := load(i.client, aFeed)
+		if err != nil {
+			i.logger.Log.Warnf("Error refreshing threat intelligence feed %s, keeping the previous indicators: %v", aFeed.Name, err)
+			continue
+		}
+
+		i.logger.Log.Infof("Loaded %d indicators from threat intelligence feed %s", len(indicators), aFeed.Name)
+		i.indicators[aFeed.Name] = indicators
+	}
+
+	newIndex := indexFrom(i.indicators)
+	i.mutex.Lock()
+	i.index = newIndex
+	i.mutex.Unlock()
+
+	i.retag()
+}
+
+// retag updates the matches of every stored ip, indicators may have been added
+// to or removed from the feeds.
+func (i *Intel) retag() {
+	metas, err := i.model.ListMetas()
+	if err != nil {
+		i.logger.Log.Warn(err)
+		return
+	}
+
+	hostnames, err := i.model.ListIpHostnames()
+	if err != nil {
+		i.logger.Log.Warn(err)
+		return
+	}
+
+	stored, err := i.model.ListThreatIntel()
+	if err != nil {
+		i.logger.Log.Warn(err)
+		return
+	}
+
+	ips := make(map[string]bool, len(metas)+len(hostnames)+len(stored))
+	for ip := range metas {
+		ips[ip] = true
+	}
+	for ip := range hostnames {
+		ips[ip] = true
+	}
+	for ip := range stored {
+		ips[ip] = true
+	}
+
+	tagged := 0
+	for ip := range ips {
+		matches := i.Match(ip, hostnames[ip])
+		if len(matches) > 0 {
+			tagged++
+		}
+		if len(matches) == 0 && len(stored[ip]) == 0 || reflect.DeepEqual(matches, stored[ip]) {
+			continue
+		}
+
+		if err := i.model.StoreThreatIntel(ip, matches); err != nil {
+			i.logger.Log.Warn(err)
+		}
+	}
+	i.logger.Log.Infof("%d of %d ips match threat intelligence feeds", tagged, len(ips))
+}
+
+func indexFrom(indicators map[string][]*indicator) *index {
+	toReturn := &index{
+		ips:      make(map[string][]model.ThreatIntelMatch),
+		networks: make([]*network, 0),
+		domains:  make(map[string][]model.ThreatIntelMatch),
+		hosts:    make(map[string][]model.ThreatIntelMatch),
+	}
+
+	for feed, someIndicators := range indicators {
+		for _, anIndicator := range someIndicators {
+			match := model.ThreatIntelMatch{
+				Feed:      feed,
+				Indicator: anIndicator.value,
+				Type:      anIndicator.kind,
+				Context:   anIndicator.context,
+			}
+
+			switch anIndicator.kind {
+			case ipIndicator:
+				toReturn.ips[anIndicator.value] = append(toReturn.ips[anIndicator.value], match)
+			case cidrIndicator:
+				_, parsedNetwork, err := net.ParseCIDR(anIndicator.value)
+				if err == nil {
+					toReturn.networks = append(toReturn.networks, &network{network: parsedNetwork, match: match})
+				}
+			case domainIndicator:
+				toReturn.domains[anIndicator.value] = append(toReturn.domains[anIndicator.value], match)
+			case urlIndicator:
+				toReturn.hosts[anIndicator.host] = append(toReturn.hosts[anIndicator.host], match)
+			}
+		}
+	}
+
+	return toReturn
+}
+
+// deduplicate keeps a match per feed and indicator, sorted so that unchanged
+// matches compare equal across refreshes.
+func deduplicate(matches []model.ThreatIntelMatch) []model.ThreatIntelMatch {
+	seen := make(map[string]bool, len(matches))
+	toReturn := make([]model.ThreatIntelMatch, 0, len(matches))
+	for _, aMatch := range matches {
+		key := aMatch.Feed + "|" + aMatch.Indicator
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		toReturn = append(toReturn, aMatch)
+	}
+
+	sort.Slice(toReturn, func(a, b int) bool {
+		if toReturn[a].Feed != toReturn[b].Feed {
+			return toReturn[a].Feed < toReturn[b].Feed
+		}
+		return toReturn[a].Indicator < toReturn[b].Indicator
+	})
+
+	return toReturn
+}
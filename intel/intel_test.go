@@ -0,0 +1,38 @@
+package intel
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	anIntel := &Intel{
+		mutex: &sync.RWMutex{},
+		index: indexFrom(map[string][]*indicator{
+			"feed": {
+				indicatorFrom("evil.example.com", ""),
+				indicatorFrom("https://payload.example.org/x", ""),
+				indicatorFrom("10.0.0.0/8", ""),
+			},
+		}),
+	}
+
+	tests := []struct {
+		ip        string
+		hostnames []string
+		want      int
+	}{
+		{"1.1.1.1", []string{"evil.example.com"}, 1},
+		{"1.1.1.1", []string{"a.evil.example.com."}, 1},
+		{"1.1.1.1", []string{"payload.example.org"}, 1},
+		{"1.1.1.1", []string{"a.payload.example.org", "example.org"}, 0},
+		{"10.1.2.3", nil, 1},
+		{"1.1.1.1", []string{"github.com"}, 0},
+	}
+
+	for _, aTest := range tests {
+		if got := anIntel.Match(aTest.ip, aTest.hostnames); len(got) != aTest.want {
+			t.Errorf("Match(%s, %v) = %v, want %d matches", aTest.ip, aTest.hostnames, got, aTest.want)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package meta
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strings"
+)
+
+// hostsNames are the entries of hosts files that are not blocked domains.
+var hostsNames = map[string]bool{
+	"localhost":             true,
+	"localhost.localdomain": true,
+	"local":                 true,
+	"broadcasthost":         true,
+	"0.0.0.0":               true,
+}
+
+// TrackerBlocklist matches hostnames against ad and tracker lists, hosts files
+// like StevenBlack ones and the domain rules of Adblock lists like
+// EasyPrivacy. Files with Adblock rules are read as Adblock lists only.
+// Exception rules of Adblock lists are honored.
+type TrackerBlocklist struct {
+	domains    map[string]bool
+	exceptions map[string]bool
+}
+
+func NewTrackerBlocklist(files []string) (*TrackerBlocklist, error) {
+	toReturn := &TrackerBlocklist{
+		domains:    make(map[string]bool),
+		exceptions: make(map[string]bool),
+	}
+
+	for _, aFile := range files {
+		if strings.TrimSpace(aFile) == "" {
+			continue
+		}
+
+		if err := toReturn.load(strings.TrimSpace(aFile)); err != nil {
+			return nil, err
+		}
+	}
+
+	return toReturn, nil
+}
+
+func (b *TrackerBlocklist) load(file string) error {
+	content, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	lines := make([]string, 0)
+	isAdblock := false
+	scanner := bufio.NewScanner(content)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		isAdblock = isAdblock || isAdblockRule(line)
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	for _, aLine := range lines {
+		if isAdblock {
+			b.addAdblockLine(aLine)
+		} else {
+			b.addHostsLine(aLine)
+		}
+	}
+
+	return nil
+}
+
+// isAdblockRule reports whether the line only makes sense in an Adblock list.
+func isAdblockRule(line string) bool {
+	return strings.HasPrefix(line, "[Adblock") || strings.HasPrefix(line, "||") || strings.HasPrefix(line, "@@") ||
+		isCosmeticRule(line)
+}
+
+func isCosmeticRule(line string) bool {
+	return strings.Contains(line, "##") || strings.Contains(line, "#@#") || strings.Contains(line, "#?#") ||
+		strings.Contains(line, "#$#")
+}
+
+// addAdblockLine keeps the rules blocking or excepting whole domains, element
+// hiding and url rules leave the domains reachable.
+func (b *TrackerBlocklist) addAdblockLine(line string) {
+	switch {
+	case isCosmeticRule(line):
+	case strings.HasPrefix(line, "@@||"):
+		if domain, ok := adblockDomain(line[4:]); ok {
+			b.exceptions[domain] = true
+		}
+	case strings.HasPrefix(line, "||"):
+		if domain, ok := adblockDomain(line[2:]); ok {
+			b.domains[domain] = true
+		}
+	}
+}
+
+// adblockDomain accepts the rules blocking a whole domain, ||domain^, and
+// refuses those with paths, wildcards or options restricting where they apply,
+// like $third-party or $domain=.
+func adblockDomain(rule string) (string, bool) {
+	if index := strings.Index(rule, "$"); index >= 0 {
+		for _, anOption := range strings.Split(rule[index+1:], ",") {
+			if !unrestrictingOptions[strings.ToLower(strings.TrimSpace(anOption))] {
+				return "", false
+			}
+		}
+		rule = rule[:index]
+	}
+
+	domain := strings.ToLower(strings.TrimSuffix(rule, "^"))
+	if !isHostname(domain) {
+		return "", false
+	}
+
+	return domain, true
+}
+
+// unrestrictingOptions are the Adblock options a rule blocking a whole domain
+// may have.
+var unrestrictingOptions = map[string]bool{
+	"important": true,
+	"all":       true,
+}
+
+// addHostsLine reads "0.0.0.0 domain" lines, and lines with just the domain.
+func (b *TrackerBlocklist) addHostsLine(line string) {
+	if index := strings.Index(line, "#"); index >= 0 {
+		line = line[:index]
+	}
+
+	fields := strings.Fields(strings.ToLower(line))
+	if len(fields) > 1 {
+		if net.ParseIP(fields[0]) == nil {
+			return
+		}
+		fields = fields[1:]
+	}
+
+	for _, aField := range fields {
+		domain := strings.TrimSuffix(aField, ".")
+		if hostsNames[domain] || strings.HasPrefix(domain, "ip6-") || !isHostname(domain) {
+			continue
+		}
+
+		b.domains[domain] = true
+	}
+}
+
+// isHostname accepts dotted names of letters, digits, hyphens and
+// underscores, ips excluded.
+func isHostname(value string) bool {
+	if len(value) > 253 || !strings.Contains(value, ".") || net.ParseIP(value) != nil {
+		return false
+	}
+
+	for _, aLabel := range strings.Split(value, ".") {
+		if aLabel == "" || len(aLabel) > 63 || strings.HasPrefix(aLabel, "-") || strings.HasSuffix(aLabel, "-") {
+			return false
+		}
+
+		for _, aRune := range aLabel {
+			if (aRune < 'a' || aRune > 'z') && (aRune < '0' || aRune > '9') && aRune != '-' && aRune != '_' {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Match returns the listed domain the hostname is or is under, empty when the
+// hostname is not listed or is excepted.
+func (b *TrackerBlocklist) Match(hostname string) string {
+	domain := strings.Trim(strings.ToLower(hostname), ".")
+	for domain != "" {
+		if b.exceptions[domain] {
+			return ""
+		}
+
+		if b.domains[domain] {
+			return domain
+		}
+
+		dot := strings.Index(domain, ".")
+		if dot < 0 {
+			break
+		}
+		domain = domain[dot+1:]
+	}
+
+	return ""
+}
+
+func (b *TrackerBlocklist) Len() int {
+	return len(b.domains)
+}
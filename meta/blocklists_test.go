@@ -0,0 +1,132 @@
+package meta
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// excerpt of https://raw.githubusercontent.com/StevenBlack/hosts/master/hosts
+const stevenBlackHosts = `# Title: StevenBlack/hosts
+#
+# This hosts file is a merged collection of hosts from reputable sources,
+# with a dash of crowd sourcing via GitHub
+#
+# ===============================================================
+
+127.0.0.1 localhost
+127.0.0.1 localhost.localdomain
+127.0.0.1 local
+255.255.255.255 broadcasthost
+::1 localhost
+::1 ip6-localhost
+::1 ip6-loopback
+fe80::1%lo0 localhost
+ff00::0 ip6-localnet
+ff00::0 ip6-mcastprefix
+ff02::1 ip6-allnodes
+ff02::2 ip6-allrouters
+ff02::3 ip6-allhosts
+0.0.0.0 0.0.0.0
+
+# Custom host records are listed here.
+
+# End of custom host records.
+# Start StevenBlack
+
+#=====================================
+# Title: Hosts contributed by Steven Black
+# http://stevenblack.com
+
+0.0.0.0 ck.getcookiestxt.com
+0.0.0.0 eu1.clevertap-prod.com
+0.0.0.0 wizhumpgyros.com
+0.0.0.0 webmail-who-int.000webhostapp.com # phishing
+0.0.0.0 -invalid-.example.com
+0.0.0.0 not/a/host.com
+ads.example.net
+`
+
+// excerpt of https://easylist.to/easylist/easyprivacy.txt
+const easyPrivacy = `[Adblock Plus 1.1]
+! Version: 202401010000
+! Title: EasyPrivacy
+! Homepage: https://easylist.to/
+!
+! *** easylist:easyprivacy/easyprivacy_trackingservers_general.txt ***
+||0emm.com^
+||1freecounter.com^
+||2o7.net^$third-party
+||adobedc.net^
+||google-analytics.com^
+||googletagmanager.com^$domain=~example.org
+||doubleclick.net^$important
+||sub.example.com/path^
+||*.tracker.example^
+! *** easylist:easyprivacy/easyprivacy_general.txt ***
+/beacon.js
+-analytics/analytics.
+.com/track?
+&action=js_stats&
+! *** easylist:easyprivacy/easyprivacy_specific_hide.txt ***
+example.com##.banner
+##.adsbox
+example.org#@#.sponsored
+example.net#?#div:-abp-has(> .ad)
+! *** easylist:easyprivacy/easyprivacy_allowlist.txt ***
+@@||adobedc.net/content/
+@@||metrics.google-analytics.com^
+@@||stats.google-analytics.com^$domain=bbc.co.uk
+`
+
+func blocklistFrom(t *testing.T, content string) *TrackerBlocklist {
+	file := filepath.Join(t.TempDir(), "list.txt")
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	toReturn, err := NewTrackerBlocklist([]string{file})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return toReturn
+}
+
+func TestTrackerBlocklist(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		length   int
+		hostname string
+		want     string
+	}{
+		{"hosts entry", stevenBlackHosts, 5, "ck.getcookiestxt.com", "ck.getcookiestxt.com"},
+		{"hosts subdomain", stevenBlackHosts, 5, "a.wizhumpgyros.com.", "wizhumpgyros.com"},
+		{"hosts commented entry", stevenBlackHosts, 5, "webmail-who-int.000webhostapp.com", "webmail-who-int.000webhostapp.com"},
+		{"hosts bare domain", stevenBlackHosts, 5, "ads.example.net", "ads.example.net"},
+		{"hosts localhost", stevenBlackHosts, 5, "localhost", ""},
+		{"hosts invalid hostname", stevenBlackHosts, 5, "-invalid-.example.com", ""},
+		{"adblock domain", easyPrivacy, 5, "www.google-analytics.com", "google-analytics.com"},
+		{"adblock important", easyPrivacy, 5, "doubleclick.net", "doubleclick.net"},
+		{"adblock exception", easyPrivacy, 5, "metrics.google-analytics.com", ""},
+		{"adblock exception restricted to a site", easyPrivacy, 5, "stats.google-analytics.com", "google-analytics.com"},
+		{"adblock exception with a path", easyPrivacy, 5, "adobedc.net", "adobedc.net"},
+		{"adblock third party", easyPrivacy, 5, "2o7.net", ""},
+		{"adblock restricted to a site", easyPrivacy, 5, "googletagmanager.com", ""},
+		{"adblock path", easyPrivacy, 5, "sub.example.com", ""},
+		{"adblock cosmetic", easyPrivacy, 5, "example.com", ""},
+		{"adblock cosmetic exception", easyPrivacy, 5, "example.org", ""},
+	}
+
+	for _, aTest := range tests {
+		blocklist := blocklistFrom(t, aTest.content)
+		if blocklist.Len() != aTest.length {
+			t.Errorf("%s: %d domains, want %d", aTest.name, blocklist.Len(), aTest.length)
+		}
+
+		if got := blocklist.Match(aTest.hostname); got != aTest.want {
+			t.Errorf("%s: Match(%q) = %q, want %q", aTest.name, aTest.hostname, got, aTest.want)
+		}
+	}
+}
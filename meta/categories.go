@@ -0,0 +1,97 @@
+package meta
+
+import (
+	"auditor/model"
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ut1Categories maps the UT1 blacklists folders, and folders named after the
+// categories themselves, to the categories. Earlier categories win when a
+// domain is in more lists.
+var ut1Categories = []struct {
+	category string
+	folders  []string
+}{
+	{category: model.AdultCategory, folders: []string{"adult", model.AdultCategory}},
+	{category: model.AdsCategory, folders: []string{"publicite", "tracking", model.AdsCategory}},
+	{category: model.GamingCategory, folders: []string{"games", model.GamingCategory}},
+	{category: model.VideoCategory, folders: []string{"audio-video", "webtv", model.VideoCategory}},
+	{category: model.SocialCategory, folders: []string{"social_networks", model.SocialCategory}},
+}
+
+// HostnameCategorizer tells the category of hostnames by the domains lists
+// of a UT1 like folder, a subfolder per category with a domains file.
+type HostnameCategorizer struct {
+	domains map[string]string
+}
+
+func NewHostnameCategorizer(folder string) (*HostnameCategorizer, error) {
+	toReturn := &HostnameCategorizer{
+		domains: make(map[string]string),
+	}
+	if strings.TrimSpace(folder) == "" {
+		return toReturn, nil
+	}
+
+	for _, aCategory := range ut1Categories {
+		for _, aFolder := range aCategory.folders {
+			err := toReturn.load(filepath.Join(folder, aFolder, "domains"), aCategory.category)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return toReturn, nil
+}
+
+func (c *HostnameCategorizer) load(file string, category string) error {
+	content, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	scanner := bufio.NewScanner(content)
+	for scanner.Scan() {
+		domain := strings.Trim(strings.ToLower(strings.TrimSpace(scanner.Text())), ".")
+		if domain == "" || strings.HasPrefix(domain, "#") {
+			continue
+		}
+
+		if _, present := c.domains[domain]; !present {
+			c.domains[domain] = category
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Categorize returns the category of the most specific listed domain the
+// hostname is or is under, empty when none is listed.
+func (c *HostnameCategorizer) Categorize(hostname string) string {
+	domain := strings.Trim(strings.ToLower(hostname), ".")
+	for domain != "" {
+		if category, ok := c.domains[domain]; ok {
+			return category
+		}
+
+		dot := strings.Index(domain, ".")
+		if dot < 0 {
+			break
+		}
+		domain = domain[dot+1:]
+	}
+
+	return ""
+}
+
+func (c *HostnameCategorizer) Len() int {
+	return len(c.domains)
+}
@@ -0,0 +1,57 @@
+package meta
+
+import (
+	"net"
+	"strings"
+)
+
+// LocalPolicy decides which addresses belong to the local network, those are
+// never sent to external providers.
+type LocalPolicy struct {
+	cidrs    []*net.IPNet
+	suffixes []string
+}
+
+func NewLocalPolicy(cidrs []*net.IPNet, suffixes []string) *LocalPolicy {
+	normalizedSuffixes := make([]string, 0, len(suffixes))
+	for _, aSuffix := range suffixes {
+		aSuffix = strings.Trim(strings.ToLower(strings.TrimSpace(aSuffix)), ".")
+		if aSuffix != "" {
+			normalizedSuffixes = append(normalizedSuffixes, aSuffix)
+		}
+	}
+
+	return &LocalPolicy{
+		cidrs:    cidrs,
+		suffixes: normalizedSuffixes,
+	}
+}
+
+// IsLocalIp matches RFC1918 and ULA ranges, link-local and loopback addresses
+// and the configured networks.
+func (p *LocalPolicy) IsLocalIp(ip net.IP) bool {
+	if ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLoopback() || ip.IsUnspecified() {
+		return true
+	}
+
+	for _, aCidr := range p.cidrs {
+		if aCidr.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsLocalName matches hostnames under one of the configured domains, with or
+// without the trailing dot of reverse lookups.
+func (p *LocalPolicy) IsLocalName(name string) bool {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	for _, aSuffix := range p.suffixes {
+		if name == aSuffix || strings.HasSuffix(name, "."+aSuffix) {
+			return true
+		}
+	}
+
+	return false
+}
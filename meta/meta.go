@@ -1,13 +1,20 @@
 package meta
 
 import (
+	"auditor/alerts"
+	"auditor/dga"
+	"auditor/events"
+	"auditor/intel"
 	logFacility "auditor/logger"
+	"auditor/metrics"
 	"auditor/model"
 	"context"
 	"errors"
+	"fmt"
 	"net"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	lru "github.com/hashicorp/golang-lru"
@@ -23,8 +30,33 @@ type MetaConfiguration struct {
 	CacheEviction *time.Duration
 
 	Dns *string
+
+	LocalCidrs    []*net.IPNet
+	LocalSuffixes []string
+
+	TorExitsFiles      []string
+	VpnRangesFiles     []string
+	HostingRangesFiles []string
+
+	CategoriesDir *string
+
+	TrackerBlocklists []string
 }
 
+// maxInFlight is the number of actions being enriched above which the
+// enrichment is considered saturated.
+const maxInFlight = 1024
+
+const (
+	// providersWindow is how often the error rates of the providers are
+	// computed, over the enrichments done in the meantime
+	providersWindow      = time.Minute
+	minProviderAttempts  = 10
+	maxProviderErrorRate = 0.5
+
+	creditsInterval = time.Hour
+)
+
 type Meta struct {
 	resolver                  *net.Resolver
 	log                       *logFacility.Logger
@@ -32,39 +64,64 @@ type Meta struct {
 	shodanClient              *shodan.Client
 	shodanHostServicesOptions *shodan.HostServicesOptions
 	cdncheck                  *cdncheck.Client
+	localPolicy               *LocalPolicy
+	ranges                    *RangesClassifier
+	categories                *HostnameCategorizer
+	trackers                  *TrackerBlocklist
+	intel                     *intel.Intel
 
 	model                *model.Model
+	events               *events.Hub
+	alerts               *alerts.Engine
 	tickersDone          chan bool
 	cachePurgeTicker     *time.Ticker
 	printCacheInfoTicker *time.Ticker
+	providersTicker      *time.Ticker
+	creditsTicker        *time.Ticker
+
+	inFlight         *atomic.Int64
+	providersMutex   *sync.RWMutex
+	providersErrs    map[string]error
+	providerOutcomes map[string]*providerOutcomes
 }
 
 func (meta *Meta) fromIp(ipAddr net.IP) (*model.Meta, error) {
 	stringIp := ipAddr.String()
 	value, isCached := meta.cache.Get(stringIp)
 	if isCached {
+		metrics.CacheLookups.WithLabelValues("hit").Inc()
 
 		return value.(*model.Meta), nil
 	}
+	metrics.CacheLookups.WithLabelValues("miss").Inc()
 
+	dnsStart := time.Now()
 	dns, err := meta.resolver.LookupAddr(context.Background(), stringIp)
+	metrics.EnrichmentDuration.WithLabelValues("dns").Observe(time.Since(dnsStart).Seconds())
 	if err != nil || len(dns) == 0 {
 		meta.log.Log.Warnf("Error looking up %v", stringIp)
+		metrics.EnrichmentErrors.WithLabelValues("dns").Inc()
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		meta.recordOutcome(DnsProvider, nil)
+	} else {
+		meta.recordOutcome(DnsProvider, err)
 	}
 
 	names := make([]string, 0)
-	isLocal := false
+	isLocal := meta.localPolicy.IsLocalIp(ipAddr)
 	for _, dnsEntry := range dns {
-		names = append(names, strings.ToLower(dnsEntry[:len(dnsEntry)-1]))
+		names = append(names, strings.ToLower(strings.TrimSuffix(dnsEntry, ".")))
 
-		if strings.HasSuffix(dnsEntry, ".lan.") {
+		if meta.localPolicy.IsLocalName(dnsEntry) {
 			isLocal = true
 		}
 	}
 
 	hostnames := names
 	if isLocal {
-		meta.log.Log.Infof("%v is a local address", hostnames)
+		meta.log.Log.Infof("%v %v is a local address", stringIp, hostnames)
 
 		toReturn := &model.Meta{
 			Hostnames: hostnames,
@@ -77,10 +134,24 @@ func (meta *Meta) fromIp(ipAddr net.IP) (*model.Meta, error) {
 		return toReturn, nil
 	}
 
+	var category, provider *string
+	if rangeCategory, rangeProvider := meta.ranges.Classify(ipAddr); rangeCategory != "" {
+		category, provider = &rangeCategory, &rangeProvider
+	}
+
+	shodanStart := time.Now()
 	host, err := meta.shodanClient.GetServicesForHost(context.Background(), stringIp, meta.shodanHostServicesOptions)
+	metrics.EnrichmentDuration.WithLabelValues("shodan").Observe(time.Since(shodanStart).Seconds())
+	meta.recordOutcome(ShodanProvider, err)
 	if err != nil {
 		meta.log.Log.Warnf("Error getting services for %v", stringIp)
+		metrics.EnrichmentErrors.WithLabelValues("shodan").Inc()
 
+		if category != nil {
+			if err := meta.model.StoreMeta(stringIp, &model.Meta{Hostnames: hostnames, Category: category, Provider: provider}); err != nil {
+				meta.log.Log.Warn(err)
+			}
+		}
 		return nil, err
 	}
 
@@ -89,12 +160,21 @@ func (meta *Meta) fromIp(ipAddr net.IP) (*model.Meta, error) {
 		hostnames = host.Hostnames
 	}
 
+	cdncheckStart := time.Now()
 	isCdn, cdnOrigin, cdnCheckErr := meta.cdncheck.Check(ipAddr)
+	metrics.EnrichmentDuration.WithLabelValues("cdncheck").Observe(time.Since(cdncheckStart).Seconds())
+	meta.recordOutcome(CdncheckProvider, cdnCheckErr)
 	if cdnCheckErr != nil {
 		meta.log.Log.Warnf("Error checking cdn for %v", stringIp)
+		metrics.EnrichmentErrors.WithLabelValues("cdncheck").Inc()
 
 		return nil, err
 	}
+	if category == nil && isCdn {
+		cdnCategory := model.CdnCategory
+		category, provider = &cdnCategory, &cdnOrigin
+	}
+
 	isp := strings.ToLower(host.ISP)
 	city := strings.ToLower(host.City)
 	countryCode := strings.ToLower(host.CountryCode)
@@ -112,6 +192,8 @@ func (meta *Meta) fromIp(ipAddr net.IP) (*model.Meta, error) {
 		Vulnerabilities: vulnerabilities,
 		IsCdn:           &isCdn,
 		Cdn:             &cdnOrigin,
+		Category:        category,
+		Provider:        provider,
 	}
 
 	meta.cache.Add(stringIp, toReturn)
@@ -140,6 +222,7 @@ func (meta *Meta) FromChan(metaChan chan *model.Action) {
 	for aMetaInput := range metaChan {
 
 		wg.Add(1)
+		meta.inFlight.Add(1)
 		go toModel(meta, aMetaInput, &wg)
 	}
 	wg.Wait()
@@ -147,13 +230,16 @@ func (meta *Meta) FromChan(metaChan chan *model.Action) {
 
 func toModel(meta *Meta, aMetaInput *model.Action, wg *sync.WaitGroup) {
 	defer wg.Done()
+	defer meta.inFlight.Add(-1)
 
-	if _, srcAddrErr := meta.fromString(*aMetaInput.SrcAddr); srcAddrErr != nil {
+	srcMeta, srcAddrErr := meta.fromString(*aMetaInput.SrcAddr)
+	if srcAddrErr != nil {
 
 		meta.log.Log.Warn(srcAddrErr)
 	}
 
-	if _, dstsrcAddrErr := meta.fromString(*aMetaInput.DstAddr); dstsrcAddrErr != nil {
+	dstMeta, dstsrcAddrErr := meta.fromString(*aMetaInput.DstAddr)
+	if dstsrcAddrErr != nil {
 
 		meta.log.Log.Warn(dstsrcAddrErr)
 	}
@@ -162,22 +248,191 @@ func toModel(meta *Meta, aMetaInput *model.Action, wg *sync.WaitGroup) {
 
 		meta.log.Log.Warn(err)
 	}
+	meta.categorize(aMetaInput)
+	meta.markTracker(aMetaInput, dstMeta)
+	dstMeta = meta.matchThreatIntel(aMetaInput, dstMeta)
+
+	event := &events.Event{
+		Time:    time.Now(),
+		Action:  aMetaInput,
+		SrcMeta: srcMeta,
+		DstMeta: dstMeta,
+
+		HostnameScore: meta.scoreHostname(aMetaInput.Hostname),
+	}
+	meta.events.Publish(event)
+	meta.alerts.Evaluate(event)
+}
+
+// matchThreatIntel matches the destination, and the hostnames it is known by or
+// reached with, against the threat intelligence feeds. Matches are stored and
+// returned on a copy of the destination meta, the cached one is shared.
+func (meta *Meta) matchThreatIntel(action *model.Action, dstMeta *model.Meta) *model.Meta {
+	hostnames := make([]string, 0)
+	if action.Hostname != nil && *action.Hostname != "" {
+		hostnames = append(hostnames, *action.Hostname)
+	}
+	if dstMeta != nil {
+		hostnames = append(hostnames, dstMeta.Hostnames...)
+	}
+
+	matches := meta.intel.Match(*action.DstAddr, hostnames)
+	if len(matches) == 0 {
+		return dstMeta
+	}
+
+	meta.log.Log.Infof("%v %v matches %d threat intelligence indicators", *action.DstAddr, hostnames, len(matches))
+	if err := meta.model.AddThreatIntel(*action.DstAddr, matches); err != nil {
+		meta.log.Log.Warn(err)
+	}
+
+	toReturn := &model.Meta{}
+	if dstMeta != nil {
+		*toReturn = *dstMeta
+	}
+	toReturn.ThreatIntel = matches
+	return toReturn
+}
+
+// scoreHostname scores the hostnames the first time they are seen.
+func (meta *Meta) scoreHostname(hostname *string) *model.HostnameScore {
+	if hostname == nil || *hostname == "" {
+		return nil
+	}
+
+	score, err := meta.model.GetHostnameScore(dga.Normalize(*hostname))
+	if err == nil {
+		return score
+	}
+	if !errors.Is(err, model.HostnameScoreNotFoundErr) {
+		meta.log.Log.Warn(err)
+	}
+
+	score = dga.Score(*hostname)
+	if err := meta.model.StoreHostnameScore(score); err != nil {
+		meta.log.Log.Warn(err)
+	}
+	if score.Suspicious {
+		meta.log.Log.Infof("%s looks algorithmically generated, score %.2f", *hostname, score.Score)
+	}
+
+	return score
+}
+
+// categorize stores the category of the hostname and counts it for the device
+// doing the action.
+func (meta *Meta) categorize(action *model.Action) {
+	if meta.categories.Len() == 0 || action.Hostname == nil || *action.Hostname == "" {
+		return
+	}
+
+	category := meta.categories.Categorize(*action.Hostname)
+	stored, err := meta.model.GetHostnameCategory(*action.Hostname)
+	if err != nil {
+		meta.log.Log.Warn(err)
+	} else if stored != category {
+		if err := meta.model.StoreHostnameCategory(*action.Hostname, category); err != nil {
+			meta.log.Log.Warn(err)
+		}
+	}
+
+	if category == "" {
+		return
+	}
+
+	at := time.Now()
+	if action.Time != nil {
+		at = *action.Time
+	}
+	if err := meta.model.RecordDeviceCategory(*action.SrcAddr, category, at); err != nil {
+		meta.log.Log.Warn(err)
+	}
+}
+
+// markTracker marks the action when its hostname, or a reverse resolved one of
+// its destination, is a listed ad or tracker, and stores the matches.
+func (meta *Meta) markTracker(action *model.Action, dstMeta *model.Meta) {
+	if meta.trackers.Len() == 0 {
+		return
+	}
+
+	hostnames := make([]string, 0)
+	if action.Hostname != nil && *action.Hostname != "" {
+		hostnames = append(hostnames, *action.Hostname)
+	}
+	if dstMeta != nil {
+		hostnames = append(hostnames, dstMeta.Hostnames...)
+	}
+
+	var hostname string
+	for _, aHostname := range hostnames {
+		domain := meta.trackers.Match(aHostname)
+		stored, err := meta.model.GetHostnameTracker(aHostname)
+		if err != nil {
+			meta.log.Log.Warn(err)
+		} else if stored != domain {
+			if err := meta.model.StoreHostnameTracker(aHostname, domain); err != nil {
+				meta.log.Log.Warn(err)
+			}
+		}
+
+		if domain != "" && action.Tracker == nil {
+			action.Tracker = &domain
+			hostname = aHostname
+		}
+	}
+
+	if action.Tracker == nil {
+		return
+	}
+
+	if err := meta.model.RecordTrackerHit(*action.SrcAddr, *action.Tracker, hostname, *action.DstAddr); err != nil {
+		meta.log.Log.Warn(err)
+	}
 }
 
 func (meta *Meta) Dispose() {
-	meta.tickersDone <- true
+	close(meta.tickersDone)
 	meta.model.Dispose()
 	meta.cachePurgeTicker.Stop()
 	meta.printCacheInfoTicker.Stop()
+	meta.providersTicker.Stop()
+	meta.creditsTicker.Stop()
 }
 
-func New(logger *logFacility.Logger, model *model.Model, metaConfs *MetaConfiguration) (*Meta, error) {
+func (meta *Meta) QueueHealth() error {
+	if inFlight := meta.inFlight.Load(); inFlight > maxInFlight {
+		return fmt.Errorf("%d actions are waiting for enrichment", inFlight)
+	}
+
+	return nil
+}
+
+func New(logger *logFacility.Logger, model *model.Model, events *events.Hub, alerts *alerts.Engine, intel *intel.Intel, metaConfs *MetaConfiguration) (*Meta, error) {
 	cache, cacheCreateErr := lru.NewARC(*metaConfs.CacheSize)
 	if cacheCreateErr != nil {
 
 		return nil, cacheCreateErr
 	}
 
+	ranges, err := NewRangesClassifier(metaConfs.TorExitsFiles, metaConfs.VpnRangesFiles, metaConfs.HostingRangesFiles)
+	if err != nil {
+		return nil, err
+	}
+	logger.Log.Infof("Loaded published ranges %v", ranges.Len())
+
+	categories, err := NewHostnameCategorizer(*metaConfs.CategoriesDir)
+	if err != nil {
+		return nil, err
+	}
+	logger.Log.Infof("Loaded %d categorized domains", categories.Len())
+
+	trackers, err := NewTrackerBlocklist(metaConfs.TrackerBlocklists)
+	if err != nil {
+		return nil, err
+	}
+	logger.Log.Infof("Loaded %d ad and tracker domains", trackers.Len())
+
 	client, err := cdncheck.NewWithCache()
 
 	if err != nil {
@@ -189,11 +444,18 @@ func New(logger *logFacility.Logger, model *model.Model, metaConfs *MetaConfigur
 		cache:        cache,
 		shodanClient: shodan.NewClient(nil, *metaConfs.ShodanApiKey),
 		model:        model,
+		events:       events,
+		alerts:       alerts,
 		shodanHostServicesOptions: &shodan.HostServicesOptions{
 			History: false,
 			Minify:  true,
 		},
-		cdncheck: client,
+		cdncheck:    client,
+		localPolicy: NewLocalPolicy(metaConfs.LocalCidrs, metaConfs.LocalSuffixes),
+		intel:       intel,
+		ranges:      ranges,
+		categories:  categories,
+		trackers:    trackers,
 		resolver: &net.Resolver{
 			PreferGo: true,
 			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
@@ -206,10 +468,18 @@ func New(logger *logFacility.Logger, model *model.Model, metaConfs *MetaConfigur
 		tickersDone:          make(chan bool),
 		cachePurgeTicker:     time.NewTicker(*metaConfs.CacheEviction),
 		printCacheInfoTicker: time.NewTicker(time.Hour / 2),
+		providersTicker:      time.NewTicker(providersWindow),
+		creditsTicker:        time.NewTicker(creditsInterval),
+		inFlight:             &atomic.Int64{},
+		providersMutex:       &sync.RWMutex{},
+		providersErrs:        make(map[string]error),
+		providerOutcomes:     make(map[string]*providerOutcomes),
 	}
 
+	metrics.RegisterCacheSize(cache.Len)
 	go toReturn.cachePurge()
 	go toReturn.printCacheInfo()
+	go toReturn.providersProbe()
 
 	return toReturn, nil
 }
@@ -238,3 +508,17 @@ func (m *Meta) printCacheInfo() {
 		}
 	}
 }
+
+func (m *Meta) providersProbe() {
+	m.refreshCredits()
+	for {
+		select {
+		case <-m.tickersDone:
+			return
+		case <-m.providersTicker.C:
+			m.checkProviders()
+		case <-m.creditsTicker.C:
+			m.refreshCredits()
+		}
+	}
+}
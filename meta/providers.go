@@ -0,0 +1,82 @@
+package meta
+
+import (
+	"auditor/metrics"
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	DnsProvider      = "dns"
+	ShodanProvider   = "shodan"
+	CdncheckProvider = "cdncheck"
+)
+
+type providerOutcomes struct {
+	attempts int
+	failures int
+}
+
+func (m *Meta) recordOutcome(provider string, err error) {
+	m.providersMutex.Lock()
+	defer m.providersMutex.Unlock()
+
+	outcomes, ok := m.providerOutcomes[provider]
+	if !ok {
+		outcomes = &providerOutcomes{}
+		m.providerOutcomes[provider] = outcomes
+	}
+
+	outcomes.attempts++
+	if err != nil {
+		outcomes.failures++
+	}
+}
+
+// checkProviders computes the error rates of the window that just ended and
+// keeps an error for every failing provider.
+func (m *Meta) checkProviders() {
+	m.providersMutex.Lock()
+	defer m.providersMutex.Unlock()
+
+	providersErrs := make(map[string]error)
+	for provider, outcomes := range m.providerOutcomes {
+		if outcomes.attempts < minProviderAttempts || float64(outcomes.failures)/float64(outcomes.attempts) <= maxProviderErrorRate {
+			continue
+		}
+
+		err := fmt.Errorf("%s failed %d of the last %d enrichments", provider, outcomes.failures, outcomes.attempts)
+		m.log.Log.Warn(err)
+		providersErrs[provider] = err
+	}
+
+	m.providersErrs = providersErrs
+	m.providerOutcomes = make(map[string]*providerOutcomes)
+}
+
+// ProviderHealth checks the error rate of the provider over the last window.
+// Only dns should gate the readiness, the enrichment goes on without the
+// others.
+func (m *Meta) ProviderHealth(provider string) func() error {
+	return func() error {
+		m.providersMutex.RLock()
+		defer m.providersMutex.RUnlock()
+
+		return m.providersErrs[provider]
+	}
+}
+
+func (m *Meta) refreshCredits() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	apiInfo, err := m.shodanClient.GetAPIInfo(ctx)
+	if err != nil {
+		m.log.Log.Warnf("Error getting the Shodan plan credits: %v", err)
+		return
+	}
+
+	metrics.ShodanQueryCredits.Set(float64(apiInfo.QueryCredits))
+	metrics.ShodanScanCredits.Set(float64(apiInfo.ScanCredits))
+}
@@ -0,0 +1,271 @@
+package meta
+
+import (
+	"auditor/model"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// RangesClassifier tells tor relays, vpn endpoints and hosting providers by
+// the published ranges loaded from local files. Categories are checked in
+// that order, so that a tor relay on a cloud instance is a tor relay.
+type RangesClassifier struct {
+	sets []*rangeSet
+}
+
+type rangeSet struct {
+	category string
+	// providers by prefix length and masked address
+	ranges  map[int]map[string]string
+	lengths []int
+}
+
+type publishedRange struct {
+	network  *net.IPNet
+	provider string
+}
+
+func NewRangesClassifier(torFiles, vpnFiles, hostingFiles []string) (*RangesClassifier, error) {
+	toReturn := &RangesClassifier{
+		sets: make([]*rangeSet, 0, 3),
+	}
+
+	for _, aCategory := range []struct {
+		name  string
+		files []string
+	}{
+		{name: model.TorCategory, files: torFiles},
+		{name: model.VpnCategory, files: vpnFiles},
+		{name: model.HostingCategory, files: hostingFiles},
+	} {
+		aSet := &rangeSet{
+			category: aCategory.name,
+			ranges:   make(map[int]map[string]string),
+		}
+
+		for _, aFile := range aCategory.files {
+			if strings.TrimSpace(aFile) == "" {
+				continue
+			}
+
+			ranges, err := readRanges(strings.TrimSpace(aFile), aCategory.name)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, aRange := range ranges {
+				aSet.add(aRange)
+			}
+		}
+
+		toReturn.sets = append(toReturn.sets, aSet)
+	}
+
+	return toReturn, nil
+}
+
+// Classify returns the category and the provider of the ip, empty when it is
+// in none of the ranges.
+func (c *RangesClassifier) Classify(ip net.IP) (string, string) {
+	for _, aSet := range c.sets {
+		if provider, ok := aSet.lookup(ip); ok {
+			return aSet.category, provider
+		}
+	}
+
+	return "", ""
+}
+
+func (c *RangesClassifier) Len() map[string]int {
+	toReturn := make(map[string]int, len(c.sets))
+	for _, aSet := range c.sets {
+		for _, someRanges := range aSet.ranges {
+			toReturn[aSet.category] += len(someRanges)
+		}
+	}
+
+	return toReturn
+}
+
+func (s *rangeSet) add(aRange *publishedRange) {
+	ones, bits := aRange.network.Mask.Size()
+	length := ones
+	if bits == 8*net.IPv6len {
+		length += 8 * net.IPv6len
+	}
+
+	someRanges, ok := s.ranges[length]
+	if !ok {
+		someRanges = make(map[string]string)
+		s.ranges[length] = someRanges
+		s.lengths = append(s.lengths, length)
+		sort.Sort(sort.Reverse(sort.IntSlice(s.lengths)))
+	}
+
+	key := aRange.network.IP.String()
+	if _, present := someRanges[key]; !present {
+		someRanges[key] = aRange.provider
+	}
+}
+
+// lookup finds the longest range containing the ip, lengths of ipv6 ranges
+// are shifted by 128 so that they never meet ipv4 ones.
+func (s *rangeSet) lookup(ip net.IP) (string, bool) {
+	bits := 8 * net.IPv4len
+	offset := 0
+	if ip.To4() == nil {
+		bits = 8 * net.IPv6len
+		offset = 8 * net.IPv6len
+	} else {
+		ip = ip.To4()
+	}
+
+	for _, aLength := range s.lengths {
+		ones := aLength - offset
+		if ones < 0 || ones > bits {
+			continue
+		}
+
+		if provider, ok := s.ranges[aLength][ip.Mask(net.CIDRMask(ones, bits)).String()]; ok {
+			return provider, true
+		}
+	}
+
+	return "", false
+}
+
+// readRanges reads the aws, google cloud and azure json files as published,
+// other files have a range per line, tor exit lists and csv geofeeds included.
+// The provider of line based files is their name, hetzner for hetzner.txt.
+func readRanges(file string, category string) ([]*publishedRange, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := strings.ToLower(strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)))
+	if category == model.TorCategory {
+		provider = model.TorCategory
+	}
+
+	var toReturn []*publishedRange
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		toReturn, err = jsonRanges(content)
+	} else {
+		toReturn, err = lineRanges(content, provider)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	return toReturn, nil
+}
+
+type publishedRanges struct {
+	Prefixes []struct {
+		AwsIpv4    string `json:"ip_prefix"`
+		AwsIpv6    string `json:"ipv6_prefix"`
+		GoogleIpv4 string `json:"ipv4Prefix"`
+		GoogleIpv6 string `json:"ipv6Prefix"`
+	} `json:"prefixes"`
+	Ipv6Prefixes []struct {
+		AwsIpv6 string `json:"ipv6_prefix"`
+	} `json:"ipv6_prefixes"`
+	Values []struct {
+		Properties struct {
+			AddressPrefixes []string `json:"addressPrefixes"`
+		} `json:"properties"`
+	} `json:"values"`
+}
+
+func jsonRanges(content []byte) ([]*publishedRange, error) {
+	published := &publishedRanges{}
+	if err := json.Unmarshal(content, published); err != nil {
+		return nil, err
+	}
+
+	toReturn := make([]*publishedRange, 0)
+	add := func(value string, provider string) {
+		if aRange := rangeFrom(value, provider); aRange != nil {
+			toReturn = append(toReturn, aRange)
+		}
+	}
+
+	for _, aPrefix := range published.Prefixes {
+		add(aPrefix.AwsIpv4, "aws")
+		add(aPrefix.AwsIpv6, "aws")
+		add(aPrefix.GoogleIpv4, "gcp")
+		add(aPrefix.GoogleIpv6, "gcp")
+	}
+
+	for _, aPrefix := range published.Ipv6Prefixes {
+		add(aPrefix.AwsIpv6, "aws")
+	}
+
+	for _, aValue := range published.Values {
+		for _, aPrefix := range aValue.Properties.AddressPrefixes {
+			add(aPrefix, "azure")
+		}
+	}
+
+	return toReturn, nil
+}
+
+func lineRanges(content []byte, provider string) ([]*publishedRange, error) {
+	toReturn := make([]*publishedRange, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if index := strings.Index(line, "#"); index >= 0 {
+			line = line[:index]
+		}
+
+		fields := strings.Fields(strings.ReplaceAll(line, ",", " "))
+		if len(fields) == 0 {
+			continue
+		}
+
+		value := fields[0]
+		if strings.EqualFold(value, "ExitAddress") && len(fields) > 1 {
+			value = fields[1]
+		}
+
+		if aRange := rangeFrom(value, provider); aRange != nil {
+			toReturn = append(toReturn, aRange)
+		}
+	}
+
+	return toReturn, scanner.Err()
+}
+
+func rangeFrom(value string, provider string) *publishedRange {
+	if value == "" {
+		return nil
+	}
+
+	if _, network, err := net.ParseCIDR(value); err == nil {
+		if ip4 := network.IP.To4(); ip4 != nil {
+			ones, _ := network.Mask.Size()
+			network = &net.IPNet{IP: ip4, Mask: net.CIDRMask(ones, 8*net.IPv4len)}
+		}
+		return &publishedRange{network: network, provider: provider}
+	}
+
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		return &publishedRange{network: &net.IPNet{IP: ip4, Mask: net.CIDRMask(8*net.IPv4len, 8*net.IPv4len)}, provider: provider}
+	}
+
+	return &publishedRange{network: &net.IPNet{IP: ip, Mask: net.CIDRMask(8*net.IPv6len, 8*net.IPv6len)}, provider: provider}
+}
@@ -0,0 +1,154 @@
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "auditor"
+
+var (
+	PacketsSeen = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "sni",
+		Name:      "packets_total",
+		Help:      "Packets captured on the interface",
+	})
+	ClientHellosParsed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "sni",
+		Name:      "client_hellos_total",
+		Help:      "ClientHellos with a server name parsed from captured packets",
+	})
+
+	FlowsReceived = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "flows",
+		Name:      "received_total",
+		Help:      "Flow messages received from the exporters",
+	})
+	FlowsFiltered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "flows",
+		Name:      "filtered_total",
+		Help:      "Flow messages dropped, by reason",
+	}, []string{"reason"})
+
+	ActionsStored = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "model",
+		Name:      "actions_stored_total",
+		Help:      "Actions stored in the database",
+	})
+	Merges = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "model",
+		Name:      "merges_total",
+		Help:      "Merge operations run by badger, by kind of value",
+	}, []string{"kind"})
+
+	EnrichmentDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "enrichment",
+		Name:      "duration_seconds",
+		Help:      "Time spent querying each enrichment provider",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"provider"})
+	EnrichmentErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "enrichment",
+		Name:      "errors_total",
+		Help:      "Failed queries to each enrichment provider",
+	}, []string{"provider"})
+	ShodanQueryCredits = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "enrichment",
+		Name:      "shodan_query_credits",
+		Help:      "Query credits left on the Shodan plan",
+	})
+	ShodanScanCredits = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "enrichment",
+		Name:      "shodan_scan_credits",
+		Help:      "Scan credits left on the Shodan plan",
+	})
+	CacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "enrichment",
+		Name:      "cache_lookups_total",
+		Help:      "Lookups in the meta cache, by result; the hit ratio is hit over the sum",
+	}, []string{"result"})
+
+	AlertsFired = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "alerts",
+		Name:      "fired_total",
+		Help:      "Alerts stored, by rule and severity",
+	}, []string{"rule", "severity"})
+	Notifications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "alerts",
+		Name:      "notifications_total",
+		Help:      "Notification deliveries, by sink and result",
+	}, []string{"sink", "result"})
+
+	ApiRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "api",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of the api requests",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+)
+
+var (
+	databaseSizeOnce sync.Once
+	databaseSize     atomic.Value
+	cacheSizeOnce    sync.Once
+	cacheSize        atomic.Value
+)
+
+// RegisterDatabaseSize exposes the sizes returned by badger's DB.Size. The
+// gauges are registered once, later calls replace the reported database.
+func RegisterDatabaseSize(size func() (int64, int64)) {
+	databaseSize.Store(size)
+	databaseSizeOnce.Do(func() {
+		promauto.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "model",
+			Name:      "lsm_size_bytes",
+			Help:      "Size of the badger LSM tree",
+		}, func() float64 {
+			lsm, _ := databaseSize.Load().(func() (int64, int64))()
+			return float64(lsm)
+		})
+		promauto.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "model",
+			Name:      "vlog_size_bytes",
+			Help:      "Size of the badger value log",
+		}, func() float64 {
+			_, vlog := databaseSize.Load().(func() (int64, int64))()
+			return float64(vlog)
+		})
+	})
+}
+
+// RegisterCacheSize exposes the number of entries held by the meta cache, the
+// same way.
+func RegisterCacheSize(size func() int) {
+	cacheSize.Store(size)
+	cacheSizeOnce.Do(func() {
+		promauto.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "enrichment",
+			Name:      "cache_entries",
+			Help:      "Entries held by the meta cache",
+		}, func() float64 {
+			return float64(cacheSize.Load().(func() int)())
+		})
+	})
+}
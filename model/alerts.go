@@ -0,0 +1,203 @@
+package model
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+const (
+	DefaultAlertsLimit = 100
+	MaxAlertsLimit     = 1000
+)
+
+type Severity uint8
+
+const (
+	Info Severity = iota
+	Low
+	Medium
+	High
+	Critical
+)
+
+var severityNames = []string{"info", "low", "medium", "high", "critical"}
+
+func SeverityFrom(value string) (Severity, error) {
+	for index, name := range severityNames {
+		if strings.EqualFold(name, value) {
+			return Severity(index), nil
+		}
+	}
+
+	return Info, fmt.Errorf("severity %s does not exist, use one of %s", value, strings.Join(severityNames, ", "))
+}
+
+func (s Severity) String() string {
+	if int(s) < len(severityNames) {
+		return severityNames[s]
+	}
+
+	return "unknown"
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	parsed, err := SeverityFrom(string(text))
+	if err != nil {
+		return err
+	}
+
+	*s = parsed
+	return nil
+}
+
+type Alert struct {
+	Id          string    `json:"id"`
+	Rule        string    `json:"rule"`
+	Severity    Severity  `json:"severity"`
+	Time        time.Time `json:"time"`
+	Description string    `json:"description"`
+	Action      *Action   `json:"action,omitempty"`
+}
+
+type AlertsQuery struct {
+	Limit       int
+	Since       time.Time
+	MinSeverity Severity
+	Rule        string
+	Source      string
+}
+
+func (q *AlertsQuery) matches(alert *Alert) bool {
+	if alert.Severity < q.MinSeverity {
+		return false
+	}
+
+	if q.Rule != "" && alert.Rule != q.Rule {
+		return false
+	}
+
+	if q.Source != "" && (alert.Action == nil || alert.Action.SrcAddr == nil || *alert.Action.SrcAddr != q.Source) {
+		return false
+	}
+
+	return true
+}
+
+// FireAlert stores an alert unless one with the same deduplication key was
+// stored within the window, returning whether it was stored.
+func (m *Model) FireAlert(alert *Alert, dedupKey string, window time.Duration) (bool, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return false, err
+	}
+	alert.Id = hex.EncodeToString(id)
+
+	bytes, err := encode(*alert)
+	if err != nil {
+		return false, err
+	}
+
+	fired := false
+	err = m.db.Update(func(txn *badger.Txn) error {
+		if window > 0 {
+			_, innerError := txn.Get(alertDedupKey(dedupKey))
+			if innerError == nil {
+				return nil
+			}
+			if !errors.Is(innerError, badger.ErrKeyNotFound) {
+				return innerError
+			}
+
+			if innerError := txn.SetEntry(badger.NewEntry(alertDedupKey(dedupKey), nil).WithTTL(window)); innerError != nil {
+				return innerError
+			}
+		}
+
+		fired = true
+		return txn.Set(alertKey(alert), bytes)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return fired, nil
+}
+
+// ListAlerts returns the alerts matching the query, most recent first.
+func (m *Model) ListAlerts(query *AlertsQuery) ([]*Alert, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultAlertsLimit
+	}
+	if limit > MaxAlertsLimit {
+		limit = MaxAlertsLimit
+	}
+
+	toReturn := make([]*Alert, 0)
+	err := m.db.View(func(txn *badger.Txn) error {
+		iteratorOptions := badger.DefaultIteratorOptions
+		iteratorOptions.Prefix = alertPrefix()
+		iteratorOptions.Reverse = true
+		iterator := txn.NewIterator(iteratorOptions)
+		defer iterator.Close()
+
+		// reverse iteration starts from the last key with the prefix
+		for iterator.Seek(append(alertPrefix(), 0xff)); iterator.Valid(); iterator.Next() {
+			valCopy, innerError := iterator.Item().ValueCopy(nil)
+			if innerError != nil {
+				return innerError
+			}
+
+			alert, innerError := decode[Alert](valCopy)
+			if innerError != nil {
+				return innerError
+			}
+
+			if alert.Time.Before(query.Since) {
+				return nil
+			}
+
+			if !query.matches(alert) {
+				continue
+			}
+
+			toReturn = append(toReturn, alert)
+			if len(toReturn) >= limit {
+				return nil
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func alertPrefix() []byte {
+	return []byte("alerts-")
+}
+
+// alertKey sorts alerts by time, so that the newest are read first.
+func alertKey(alert *Alert) []byte {
+	toReturn := alertPrefix()
+	toReturn = binary.BigEndian.AppendUint64(toReturn, uint64(alert.Time.UnixNano()))
+	return append(toReturn, []byte(alert.Id)...)
+}
+
+func alertDedupKey(dedupKey string) []byte {
+	return []byte("alert-dedup-" + dedupKey)
+}
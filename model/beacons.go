@@ -0,0 +1,260 @@
+package model
+
+import (
+	"auditor/metrics"
+	"errors"
+	"sort"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+const (
+	DefaultBeaconsLimit = 100
+	MaxBeaconsLimit     = 1000
+
+	maxContactTimes   = 128
+	ContactsRetention = 7 * 24 * time.Hour
+)
+
+// Contacts are the latest times, in unix seconds, a source contacted each of
+// its destinations, keyed by hostname when known or by destination ip.
+type Contacts struct {
+	Ip       *string
+	Contacts map[string]*Contact
+}
+
+type Contact struct {
+	Destination string
+	Hostname    string
+	Times       []int64
+}
+
+// Beacon is a source contacting a destination at regular intervals, Score
+// goes from 0 to 1 as the intervals get more regular and more numerous.
+type Beacon struct {
+	Source          string    `json:"source"`
+	Destination     string    `json:"destination"`
+	Hostname        string    `json:"hostname,omitempty"`
+	Connections     int       `json:"connections"`
+	IntervalSeconds float64   `json:"intervalSeconds"`
+	JitterSeconds   float64   `json:"jitterSeconds"`
+	Score           float64   `json:"score"`
+	FirstSeen       time.Time `json:"firstSeen"`
+	LastSeen        time.Time `json:"lastSeen"`
+}
+
+type BeaconsQuery struct {
+	Limit    int
+	MinScore float64
+	Source   string
+}
+
+// storeContact records when the action happened, callers hold the actions
+// mutex.
+func (m *Model) storeContact(action *Action) error {
+	seenAt := time.Now()
+	if action.Time != nil && !action.Time.IsZero() {
+		seenAt = *action.Time
+	}
+
+	target := *action.DstAddr
+	hostname := ""
+	if action.Hostname != nil && *action.Hostname != "" {
+		target = *action.Hostname
+		hostname = *action.Hostname
+	}
+
+	bytes, err := encode(Contacts{
+		Ip: action.SrcAddr,
+		Contacts: map[string]*Contact{
+			target: {
+				Destination: *action.DstAddr,
+				Hostname:    hostname,
+				Times:       []int64{seenAt.Unix()},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	mergingOperator, ok := m.contactsMerger[*action.SrcAddr]
+	if !ok {
+		mergingOperator = m.db.GetMergeOperator(contactsKey(*action.SrcAddr), m.mergeContacts, 100*time.Millisecond)
+		m.contactsMerger[*action.SrcAddr] = mergingOperator
+	}
+
+	mergingOperator.Add(bytes)
+	return nil
+}
+
+func (m *Model) ListContacts() ([]*Contacts, error) {
+	toReturn := make([]*Contacts, 0)
+	err := m.db.View(func(txn *badger.Txn) error {
+		iteratorOptions := badger.DefaultIteratorOptions
+		iteratorOptions.Prefix = contactsPrefix()
+		iterator := txn.NewIterator(iteratorOptions)
+		defer iterator.Close()
+
+		for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+			valCopy, innerError := iterator.Item().ValueCopy(nil)
+			if innerError != nil {
+				return innerError
+			}
+
+			contacts, innerError := decode[Contacts](valCopy)
+			if innerError != nil {
+				return innerError
+			}
+
+			toReturn = append(toReturn, contacts)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+// StoreBeacons replaces the beacons found by the previous analysis.
+func (m *Model) StoreBeacons(beacons []*Beacon) error {
+	bytes, err := encode(beacons)
+	if err != nil {
+		return err
+	}
+
+	return m.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(beaconsKey(), bytes)
+	})
+}
+
+// ListBeacons returns the beacons matching the query, highest score first.
+func (m *Model) ListBeacons(query *BeaconsQuery) ([]*Beacon, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultBeaconsLimit
+	}
+	if limit > MaxBeaconsLimit {
+		limit = MaxBeaconsLimit
+	}
+
+	var stored []*Beacon
+	err := m.db.View(func(txn *badger.Txn) error {
+		item, innerError := txn.Get(beaconsKey())
+		if errors.Is(innerError, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if innerError != nil {
+			return innerError
+		}
+
+		valCopy, innerError := item.ValueCopy(nil)
+		if innerError != nil {
+			return innerError
+		}
+
+		decoded, innerError := decode[[]*Beacon](valCopy)
+		if innerError != nil {
+			return innerError
+		}
+		stored = *decoded
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	toReturn := make([]*Beacon, 0)
+	for _, aBeacon := range stored {
+		if aBeacon.Score < query.MinScore {
+			continue
+		}
+
+		if query.Source != "" && aBeacon.Source != query.Source {
+			continue
+		}
+
+		toReturn = append(toReturn, aBeacon)
+	}
+
+	sort.SliceStable(toReturn, func(i, j int) bool {
+		return toReturn[i].Score > toReturn[j].Score
+	})
+	if len(toReturn) > limit {
+		toReturn = toReturn[:limit]
+	}
+
+	return toReturn, nil
+}
+
+func (m *Model) mergeContacts(originalValue, newValue []byte) []byte {
+	metrics.Merges.WithLabelValues("contacts").Inc()
+	originalDecoded, originalDecodeErr := decode[Contacts](originalValue)
+	newDecoded, newDecodeErr := decode[Contacts](newValue)
+	if originalDecodeErr != nil || newDecodeErr != nil {
+		return originalValue
+	}
+
+	for target, newContact := range newDecoded.Contacts {
+		oldContact, isContactPresent := originalDecoded.Contacts[target]
+		if !isContactPresent {
+			originalDecoded.Contacts[target] = newContact
+			continue
+		}
+
+		oldContact.Destination = newContact.Destination
+		oldContact.Times = mergeTimes(oldContact.Times, newContact.Times)
+	}
+
+	oldest := time.Now().Add(-ContactsRetention).Unix()
+	for target, aContact := range originalDecoded.Contacts {
+		if len(aContact.Times) == 0 || aContact.Times[len(aContact.Times)-1] < oldest {
+			delete(originalDecoded.Contacts, target)
+		}
+	}
+
+	newBytes, encodingErr := encode(originalDecoded)
+	if encodingErr != nil {
+		return originalValue
+	}
+
+	return newBytes
+}
+
+// mergeTimes returns the sorted union of the times, keeping the latest ones.
+func mergeTimes(original, added []int64) []int64 {
+	toReturn := append(original, added...)
+	sort.Slice(toReturn, func(i, j int) bool {
+		return toReturn[i] < toReturn[j]
+	})
+
+	unique := toReturn[:0]
+	for _, value := range toReturn {
+		if len(unique) > 0 && value == unique[len(unique)-1] {
+			continue
+		}
+		unique = append(unique, value)
+	}
+
+	if len(unique) > maxContactTimes {
+		unique = unique[len(unique)-maxContactTimes:]
+	}
+
+	return unique
+}
+
+func contactsPrefix() []byte {
+	return []byte("contacts-")
+}
+
+func contactsKey(ip string) []byte {
+	return append(contactsPrefix(), []byte(ip)...)
+}
+
+func beaconsKey() []byte {
+	return []byte("beacons")
+}
@@ -0,0 +1,278 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"auditor/metrics"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+const (
+	SocialCategory = "social"
+	VideoCategory  = "video"
+	GamingCategory = "gaming"
+	AdsCategory    = "ads"
+	AdultCategory  = "adult"
+
+	// CategoriesBucket is the finest granularity of the device summaries
+	CategoriesBucket    = time.Hour
+	categoriesRetention = 90 * 24 * time.Hour
+)
+
+var HostnameCategories = []string{SocialCategory, VideoCategory, GamingCategory, AdsCategory, AdultCategory}
+
+// CategoryBucket counts the actions of a device per category of their
+// hostnames, from Start for the requested bucket size.
+type CategoryBucket struct {
+	Start      time.Time      `json:"start"`
+	Categories map[string]int `json:"categories"`
+}
+
+// StoreHostnameCategory records the category of a hostname, removing it when
+// the category is empty.
+func (m *Model) StoreHostnameCategory(hostname string, category string) error {
+	return m.db.Update(func(txn *badger.Txn) error {
+		if category == "" {
+			return txn.Delete(hostnameCategoryKey(hostname))
+		}
+
+		return txn.Set(hostnameCategoryKey(hostname), []byte(category))
+	})
+}
+
+// GetHostnameCategory returns the stored category of the hostname, empty when
+// it has none.
+func (m *Model) GetHostnameCategory(hostname string) (string, error) {
+	var toReturn string
+	err := m.db.View(func(txn *badger.Txn) error {
+		item, innerError := txn.Get(hostnameCategoryKey(hostname))
+		if errors.Is(innerError, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if innerError != nil {
+			return innerError
+		}
+
+		valCopy, innerError := item.ValueCopy(nil)
+		toReturn = string(valCopy)
+		return innerError
+	})
+
+	return toReturn, err
+}
+
+// ListCategorizedHostnames returns the hostnames stored with one of the
+// categories.
+func (m *Model) ListCategorizedHostnames(categories []string) ([]string, error) {
+	return m.listHostnames(hostnameCategoryPrefix(), func(value string) bool {
+		for _, aCategory := range categories {
+			if strings.EqualFold(aCategory, value) {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+// RecordDeviceCategory counts an action in the category of its hostname for
+// the device using the ip, actions of ips without a device are not counted.
+func (m *Model) RecordDeviceCategory(ip string, category string, at time.Time) error {
+	mac, err := m.getDeviceMac(ip)
+	if err != nil || mac == "" {
+		return err
+	}
+
+	bytes, err := encode(map[string]int{category: 1})
+	if err != nil {
+		return err
+	}
+
+	start := at.Truncate(CategoriesBucket)
+	key := deviceCategoriesKey(mac, start)
+
+	m.categoriesMutex.Lock()
+	defer m.categoriesMutex.Unlock()
+	merger, ok := m.categoriesMerger[string(key)]
+	if !ok {
+		m.expireCategoriesMergers(time.Now().Truncate(CategoriesBucket).Add(-CategoriesBucket))
+		merger = &categoriesMerger{
+			start:    start,
+			operator: m.db.GetMergeOperator(key, m.mergeCategories, 100*time.Millisecond),
+		}
+		m.categoriesMerger[string(key)] = merger
+	}
+
+	merger.operator.Add(bytes)
+	return nil
+}
+
+// ListDeviceCategories sums the category counts of the device since the given
+// time in buckets of the given size, a multiple of CategoriesBucket.
+func (m *Model) ListDeviceCategories(mac string, since time.Time, bucket time.Duration) ([]*CategoryBucket, error) {
+	byStart := make(map[time.Time]*CategoryBucket)
+	err := m.db.View(func(txn *badger.Txn) error {
+		if _, innerError := getDevice(txn, mac); innerError != nil {
+			return innerError
+		}
+
+		iteratorOptions := badger.DefaultIteratorOptions
+		iteratorOptions.Prefix = deviceCategoriesPrefix(mac)
+		iterator := txn.NewIterator(iteratorOptions)
+		defer iterator.Close()
+
+		for iterator.Seek(deviceCategoriesKey(mac, since.Truncate(CategoriesBucket))); iterator.Valid(); iterator.Next() {
+			start, innerError := strconv.ParseInt(strings.TrimPrefix(string(iterator.Item().Key()), string(deviceCategoriesPrefix(mac))), 16, 64)
+			if innerError != nil {
+				return innerError
+			}
+
+			valCopy, innerError := iterator.Item().ValueCopy(nil)
+			if innerError != nil {
+				return innerError
+			}
+
+			counts, innerError := decode[map[string]int](valCopy)
+			if innerError != nil {
+				return innerError
+			}
+
+			bucketStart := time.Unix(start, 0).Truncate(bucket).UTC()
+			aBucket, ok := byStart[bucketStart]
+			if !ok {
+				aBucket = &CategoryBucket{
+					Start:      bucketStart,
+					Categories: make(map[string]int),
+				}
+				byStart[bucketStart] = aBucket
+			}
+
+			for category, count := range *counts {
+				aBucket.Categories[category] += count
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	toReturn := make([]*CategoryBucket, 0, len(byStart))
+	for _, aBucket := range byStart {
+		toReturn = append(toReturn, aBucket)
+	}
+	sort.Slice(toReturn, func(i, j int) bool {
+		return toReturn[i].Start.Before(toReturn[j].Start)
+	})
+
+	return toReturn, nil
+}
+
+type categoriesMerger struct {
+	start    time.Time
+	operator *badger.MergeOperator
+}
+
+// expireCategoriesMergers stops the mergers of the buckets started before the
+// given time and sets the retention of their counts, the merge operators write
+// them without one.
+func (m *Model) expireCategoriesMergers(before time.Time) {
+	for key, merger := range m.categoriesMerger {
+		if !merger.start.Before(before) {
+			continue
+		}
+
+		merger.operator.Stop()
+		delete(m.categoriesMerger, key)
+
+		err := m.db.Update(func(txn *badger.Txn) error {
+			item, innerError := txn.Get([]byte(key))
+			if innerError != nil {
+				return innerError
+			}
+
+			valCopy, innerError := item.ValueCopy(nil)
+			if innerError != nil {
+				return innerError
+			}
+
+			return txn.SetEntry(badger.NewEntry([]byte(key), valCopy).WithTTL(categoriesRetention))
+		})
+		if err != nil {
+			m.logger.Log.Warnf("Cannot set the retention of %s: %v", key, err)
+		}
+	}
+}
+
+func (m *Model) mergeCategories(originalValue, newValue []byte) []byte {
+	metrics.Merges.WithLabelValues("categories").Inc()
+	originalDecoded, originalDecodeErr := decode[map[string]int](originalValue)
+	newDecoded, newDecodeErr := decode[map[string]int](newValue)
+	if originalDecodeErr != nil || newDecodeErr != nil {
+		return originalValue
+	}
+
+	for category, count := range *newDecoded {
+		(*originalDecoded)[category] += count
+	}
+
+	newBytes, encodingErr := encode(originalDecoded)
+	if encodingErr != nil {
+		return originalValue
+	}
+
+	return newBytes
+}
+
+// listHostnames returns the hostnames of the keys with the prefix whose value
+// is accepted.
+func (m *Model) listHostnames(prefix []byte, accept func(value string) bool) ([]string, error) {
+	toReturn := make([]string, 0)
+	err := m.db.View(func(txn *badger.Txn) error {
+		iteratorOptions := badger.DefaultIteratorOptions
+		iteratorOptions.Prefix = prefix
+		iterator := txn.NewIterator(iteratorOptions)
+		defer iterator.Close()
+
+		for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+			valCopy, innerError := iterator.Item().ValueCopy(nil)
+			if innerError != nil {
+				return innerError
+			}
+
+			if accept(string(valCopy)) {
+				toReturn = append(toReturn, strings.TrimPrefix(string(iterator.Item().Key()), string(prefix)))
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func hostnameCategoryPrefix() []byte {
+	return []byte("hostname-categories-")
+}
+
+func hostnameCategoryKey(hostname string) []byte {
+	return append(hostnameCategoryPrefix(), []byte(hostname)...)
+}
+
+func deviceCategoriesPrefix(mac string) []byte {
+	return []byte(fmt.Sprintf("device-categories-%s-", mac))
+}
+
+func deviceCategoriesKey(mac string, start time.Time) []byte {
+	return append(deviceCategoriesPrefix(mac), []byte(fmt.Sprintf("%016x", start.Unix()))...)
+}
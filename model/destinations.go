@@ -0,0 +1,317 @@
+package model
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+const DefaultLearningPeriod = 7 * 24 * time.Hour
+
+// NewDestination is a destination, or a new hostname of a known destination,
+// a source started talking to after its learning period.
+type NewDestination struct {
+	Time        time.Time `json:"time"`
+	Source      string    `json:"source"`
+	Destination string    `json:"destination"`
+	Hostnames   []string  `json:"hostnames,omitempty"`
+}
+
+// ListNewDestinations returns what the ip started talking to since the given
+// time, oldest first.
+func (m *Model) ListNewDestinations(ip string, since time.Time) ([]*NewDestination, error) {
+	toReturn := make([]*NewDestination, 0)
+	err := m.db.View(func(txn *badger.Txn) error {
+		iteratorOptions := badger.DefaultIteratorOptions
+		iteratorOptions.Prefix = newDestinationPrefix(ip)
+		iterator := txn.NewIterator(iteratorOptions)
+		defer iterator.Close()
+
+		seek := binary.BigEndian.AppendUint64(newDestinationPrefix(ip), uint64(since.UnixNano()))
+		for iterator.Seek(seek); iterator.Valid(); iterator.Next() {
+			valCopy, innerError := iterator.Item().ValueCopy(nil)
+			if innerError != nil {
+				return innerError
+			}
+
+			newDestination, innerError := decode[NewDestination](valCopy)
+			if innerError != nil {
+				return innerError
+			}
+
+			toReturn = append(toReturn, newDestination)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+// recordNewDestination marks the destination, and its hostnames, as seen by
+// the source, recording them when they are new and the source is not learning
+// anymore. Recently marked ones are skipped without reading the database.
+func (m *Model) recordNewDestination(ip, destination string, hostnames []string) error {
+	unmarked := make([]string, 0, len(hostnames))
+	for _, aHostname := range hostnames {
+		if !m.destinations.Contains(seenTuple(ip, destination, aHostname)) {
+			unmarked = append(unmarked, aHostname)
+		}
+	}
+	if len(unmarked) == 0 && m.destinations.Contains(seenTuple(ip, destination, "")) {
+		return nil
+	}
+	hostnames = unmarked
+
+	now := time.Now()
+	var newDestination *NewDestination
+	err := m.db.Update(func(txn *badger.Txn) error {
+		seen, known, innerError := getSeenHostnames(txn, ip, destination)
+		if innerError != nil {
+			return innerError
+		}
+
+		newHostnames := make([]string, 0, len(hostnames))
+		for _, aHostname := range hostnames {
+			if !contains(seen, aHostname) && !contains(newHostnames, aHostname) {
+				newHostnames = append(newHostnames, aHostname)
+			}
+		}
+		if known && len(newHostnames) == 0 {
+			return nil
+		}
+
+		bytes, innerError := encode(append(seen, newHostnames...))
+		if innerError != nil {
+			return innerError
+		}
+
+		if innerError := txn.Set(seenDestinationKey(ip, destination), bytes); innerError != nil {
+			return innerError
+		}
+
+		learning, innerError := m.isLearning(txn, ip, now)
+		if innerError != nil || learning {
+			return innerError
+		}
+
+		newDestination = &NewDestination{
+			Time:        now,
+			Source:      ip,
+			Destination: destination,
+			Hostnames:   newHostnames,
+		}
+
+		bytes, innerError = encode(*newDestination)
+		if innerError != nil {
+			return innerError
+		}
+
+		return txn.Set(newDestinationKey(newDestination), bytes)
+	})
+	if err != nil {
+		return err
+	}
+
+	m.destinations.Add(seenTuple(ip, destination, ""), true)
+	for _, aHostname := range hostnames {
+		m.destinations.Add(seenTuple(ip, destination, aHostname), true)
+	}
+
+	if newDestination == nil {
+		return nil
+	}
+
+	select {
+	case m.NewDestinations <- newDestination:
+	default:
+		m.logger.Log.Debugf("Nobody is listening for new destinations, %s to %s not sent", ip, destination)
+	}
+
+	return nil
+}
+
+func getSeenHostnames(txn *badger.Txn, ip, destination string) ([]string, bool, error) {
+	item, err := txn.Get(seenDestinationKey(ip, destination))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return []string{}, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	valCopy, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, false, err
+	}
+
+	decoded, err := decode[[]string](valCopy)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return *decoded, true, nil
+}
+
+// seedSeenDestinations marks the traffic stored by older versions as seen, so
+// that it is not reported as new.
+func (m *Model) seedSeenDestinations() error {
+	alreadySeeded := false
+	err := m.db.View(func(txn *badger.Txn) error {
+		_, innerError := txn.Get(seenDestinationsSeededKey())
+		alreadySeeded = innerError == nil
+		return nil
+	})
+	if err != nil || alreadySeeded {
+		return err
+	}
+
+	batch := m.db.NewWriteBatch()
+	defer batch.Cancel()
+
+	err = m.db.View(func(txn *badger.Txn) error {
+		iterator := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer iterator.Close()
+
+		for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+			ip := strings.TrimSuffix(string(iterator.Item().Key()), "-action")
+			if ip == string(iterator.Item().Key()) {
+				continue
+			}
+
+			valCopy, innerError := iterator.Item().ValueCopy(nil)
+			if innerError != nil {
+				return innerError
+			}
+
+			actions, decodeErr := decode[ActionsByIp](valCopy)
+			if decodeErr != nil {
+				continue
+			}
+
+			for destination, hostnames := range actions.Traffic {
+				bytes, innerError := encode(hostnames)
+				if innerError != nil {
+					return innerError
+				}
+
+				if innerError := batch.Set(seenDestinationKey(ip, destination), bytes); innerError != nil {
+					return innerError
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := batch.Set(seenDestinationsSeededKey(), []byte{}); err != nil {
+		return err
+	}
+
+	return batch.Flush()
+}
+
+func contains(values []string, value string) bool {
+	for _, aValue := range values {
+		if aValue == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isLearning tells whether the source was first seen within the learning
+// period, using the device behind the ip when it is known.
+func (m *Model) isLearning(txn *badger.Txn, ip string, now time.Time) (bool, error) {
+	var firstSeen time.Time
+	known := false
+	err := func() error {
+		item, innerError := txn.Get(deviceIpKey(ip))
+		if innerError == nil {
+			valCopy, innerError := item.ValueCopy(nil)
+			if innerError != nil {
+				return innerError
+			}
+
+			device, innerError := getDevice(txn, string(valCopy))
+			if innerError == nil {
+				firstSeen = device.FirstSeen
+				known = true
+				return nil
+			}
+			if !errors.Is(innerError, DeviceNotFoundErr) {
+				return innerError
+			}
+		} else if !errors.Is(innerError, badger.ErrKeyNotFound) {
+			return innerError
+		}
+
+		item, innerError = txn.Get(ipEntryKey(ip))
+		if errors.Is(innerError, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if innerError != nil {
+			return innerError
+		}
+
+		valCopy, innerError := item.ValueCopy(nil)
+		if innerError != nil {
+			return innerError
+		}
+
+		entry, innerError := decode[IpEntry](valCopy)
+		if innerError != nil {
+			return innerError
+		}
+		firstSeen = entry.FirstSeen
+		known = true
+		return nil
+	}()
+	if err != nil {
+		return false, err
+	}
+
+	// ips migrated from older versions have no first seen, they are old enough
+	return !known || now.Sub(firstSeen) < m.configuration.learningPeriod(), nil
+}
+
+func (c *ModelConfigurations) learningPeriod() time.Duration {
+	if c.LearningPeriod == nil {
+		return DefaultLearningPeriod
+	}
+
+	return *c.LearningPeriod
+}
+
+func seenTuple(ip, destination, hostname string) string {
+	return ip + "|" + destination + "|" + hostname
+}
+
+func seenDestinationKey(ip, destination string) []byte {
+	return []byte("seen-destinations-" + ip + "-" + destination)
+}
+
+func seenDestinationsSeededKey() []byte {
+	return []byte("seen-destinations-seeded")
+}
+
+func newDestinationPrefix(ip string) []byte {
+	return []byte("new-destinations-" + ip + "-")
+}
+
+// newDestinationKey sorts the new destinations of an ip by time.
+func newDestinationKey(newDestination *NewDestination) []byte {
+	toReturn := newDestinationPrefix(newDestination.Source)
+	toReturn = binary.BigEndian.AppendUint64(toReturn, uint64(newDestination.Time.UnixNano()))
+	return append(toReturn, []byte(newDestination.Destination)...)
+}
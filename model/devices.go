@@ -0,0 +1,377 @@
+package model
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"auditor/oui"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// sightingInterval throttles the writes of a mac to ip association, since
+// every captured packet proves it again.
+const sightingInterval = time.Minute
+
+// Sighting associates a mac to an ip, the ip is empty for DHCP discovers
+// sent before an address is leased.
+type Sighting struct {
+	Mac  string
+	Ip   string
+	Dhcp *DhcpInfo
+}
+
+type DhcpInfo struct {
+	Hostname    string `json:"hostname,omitempty"`
+	VendorClass string `json:"vendorClass,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Os          string `json:"os,omitempty"`
+	DeviceType  string `json:"deviceType,omitempty"`
+}
+
+func (d *DhcpInfo) merge(newInfo *DhcpInfo) {
+	if newInfo.Hostname != "" {
+		d.Hostname = newInfo.Hostname
+	}
+
+	if newInfo.VendorClass != "" {
+		d.VendorClass = newInfo.VendorClass
+	}
+
+	if newInfo.Fingerprint != "" {
+		d.Fingerprint = newInfo.Fingerprint
+	}
+
+	if newInfo.Os != "" {
+		d.Os = newInfo.Os
+	}
+
+	if newInfo.DeviceType != "" {
+		d.DeviceType = newInfo.DeviceType
+	}
+}
+
+// isEmpty reports whether the client sent nothing describing it, as with the
+// sightings of acknowledgements.
+func (d *DhcpInfo) isEmpty() bool {
+	return d.Hostname == "" && d.VendorClass == "" && d.Fingerprint == ""
+}
+
+func (d *DhcpInfo) toMeta() *Meta {
+	toReturn := &Meta{}
+	if d.Hostname != "" {
+		toReturn.Hostnames = []string{strings.ToLower(d.Hostname)}
+	}
+
+	if d.VendorClass != "" {
+		toReturn.VendorClass = &d.VendorClass
+	}
+
+	if d.Os != "" {
+		toReturn.Os = &d.Os
+	}
+
+	if d.DeviceType != "" {
+		toReturn.DeviceType = &d.DeviceType
+	}
+
+	return toReturn
+}
+
+type DeviceAddress struct {
+	Ip        string    `json:"ip"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+type Device struct {
+	Mac       string           `json:"mac"`
+	Vendor    string           `json:"vendor,omitempty"`
+	FirstSeen time.Time        `json:"firstSeen"`
+	LastSeen  time.Time        `json:"lastSeen"`
+	Addresses []*DeviceAddress `json:"addresses"`
+	Dhcp      *DhcpInfo        `json:"dhcp,omitempty"`
+	// Labels are resolved when listing, they are not stored with the device
+	Labels *Labels `json:"labels,omitempty"`
+}
+
+type DevicesFilter struct {
+	Label *string
+	Owner *string
+}
+
+func (m *Model) FromSightings(sightings chan *Sighting) {
+	for aSighting := range sightings {
+		if err := m.StoreSighting(aSighting); err != nil {
+
+			m.logger.Log.Warn(err)
+		}
+	}
+}
+
+func (m *Model) StoreSighting(sighting *Sighting) error {
+	mac, err := net.ParseMAC(sighting.Mac)
+	if err != nil {
+		return err
+	}
+
+	m.devicesMutex.Lock()
+	defer m.devicesMutex.Unlock()
+
+	seenAt := time.Now()
+	sightingKey := sighting.Mac + "|" + sighting.Ip
+	lastStored, ok := m.sightings.Get(sightingKey)
+	if sighting.Dhcp == nil && ok && seenAt.Sub(lastStored.(time.Time)) < sightingInterval {
+		return nil
+	}
+
+	var dhcpInfo *DhcpInfo
+	err = m.db.Update(func(txn *badger.Txn) error {
+		device, innerError := getDevice(txn, mac.String())
+		if errors.Is(innerError, DeviceNotFoundErr) {
+			device = &Device{
+				Mac:       mac.String(),
+				Vendor:    oui.Vendor(mac),
+				FirstSeen: seenAt,
+			}
+		} else if innerError != nil {
+			return innerError
+		}
+		device.LastSeen = seenAt
+
+		if sighting.Dhcp != nil {
+			if device.Dhcp == nil {
+				device.Dhcp = &DhcpInfo{}
+			}
+			device.Dhcp.merge(sighting.Dhcp)
+		}
+
+		if sighting.Ip != "" && device.touchAddress(sighting.Ip, seenAt) {
+			m.logger.Log.Infof("Device %s is using %s", device.Mac, sighting.Ip)
+		}
+		dhcpInfo = device.Dhcp
+
+		bytes, innerError := encode(*device)
+		if innerError != nil {
+			return innerError
+		}
+
+		if innerError := txn.Set(deviceKey(device.Mac), bytes); innerError != nil {
+			return innerError
+		}
+
+		if sighting.Ip == "" {
+			return nil
+		}
+
+		return txn.Set(deviceIpKey(sighting.Ip), []byte(device.Mac))
+	})
+	if err != nil {
+		return err
+	}
+
+	m.sightings.Add(sightingKey, seenAt)
+
+	if sighting.Dhcp != nil && !sighting.Dhcp.isEmpty() && sighting.Ip != "" {
+		return m.StoreMeta(sighting.Ip, dhcpInfo.toMeta())
+	}
+
+	return nil
+}
+
+// touchAddress returns true when the device was not known to use the ip.
+func (d *Device) touchAddress(ip string, seenAt time.Time) bool {
+	for _, anAddress := range d.Addresses {
+		if anAddress.Ip == ip {
+			anAddress.LastSeen = seenAt
+			return false
+		}
+	}
+
+	d.Addresses = append(d.Addresses, &DeviceAddress{
+		Ip:        ip,
+		FirstSeen: seenAt,
+		LastSeen:  seenAt,
+	})
+	return true
+}
+
+// ListDevices returns the devices matching the filter, most recently seen
+// first, a nil filter matches them all.
+func (m *Model) ListDevices(filter *DevicesFilter) ([]*Device, error) {
+	toReturn := make([]*Device, 0)
+	err := m.db.View(func(txn *badger.Txn) error {
+		iteratorOptions := badger.DefaultIteratorOptions
+		iteratorOptions.Prefix = devicePrefix()
+		iterator := txn.NewIterator(iteratorOptions)
+		defer iterator.Close()
+
+		for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+			valCopy, innerError := iterator.Item().ValueCopy(nil)
+			if innerError != nil {
+				return innerError
+			}
+
+			device, innerError := decode[Device](valCopy)
+			if innerError != nil {
+				return innerError
+			}
+
+			labels, innerError := getLabels(txn, deviceLabelsKey(device.Mac))
+			if innerError != nil && !errors.Is(innerError, LabelsNotFoundErr) {
+				return innerError
+			}
+			device.Labels = labels
+
+			if filter != nil && (filter.Label != nil || filter.Owner != nil) {
+				if labels == nil || !labels.matches(filter.Label, filter.Owner) {
+					continue
+				}
+			}
+
+			toReturn = append(toReturn, device)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(toReturn, func(i, j int) bool {
+		return toReturn[i].LastSeen.After(toReturn[j].LastSeen)
+	})
+
+	return toReturn, nil
+}
+
+func (m *Model) GetDevice(mac string) (*Device, error) {
+	var toReturn *Device
+	err := m.db.View(func(txn *badger.Txn) error {
+		device, innerError := getDevice(txn, mac)
+		if innerError != nil {
+			return innerError
+		}
+
+		toReturn = device
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+// GetDeviceActions returns the traffic of a device across all the ips it
+// used, as attributed when each action was stored.
+func (m *Model) GetDeviceActions(mac string) (*ActionsByIp, error) {
+	var toReturn *ActionsByIp
+	err := m.db.View(func(txn *badger.Txn) error {
+		if _, innerError := getDevice(txn, mac); innerError != nil {
+			return innerError
+		}
+
+		item, innerError := txn.Get(deviceActionKey(mac))
+		if errors.Is(innerError, badger.ErrKeyNotFound) {
+			toReturn = &ActionsByIp{
+				Traffic: make(map[string][]string),
+			}
+			return nil
+		}
+		if innerError != nil {
+			return innerError
+		}
+
+		valCopy, innerError := item.ValueCopy(nil)
+		if innerError != nil {
+			return innerError
+		}
+
+		toReturn, innerError = decode[ActionsByIp](valCopy)
+		return innerError
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+// storeDeviceAction adds the encoded actions of an ip to the device currently
+// using it, callers hold the actions mutex.
+func (m *Model) storeDeviceAction(ip string, actionsBytes []byte) error {
+	mac, err := m.getDeviceMac(ip)
+	if err != nil || mac == "" {
+		return err
+	}
+
+	mergingOperator, ok := m.deviceActionsMerger[mac]
+	if !ok {
+		mergingOperator = m.db.GetMergeOperator(deviceActionKey(mac), m.mergeActions, 100*time.Millisecond)
+		m.deviceActionsMerger[mac] = mergingOperator
+	}
+
+	mergingOperator.Add(actionsBytes)
+	return nil
+}
+
+// getDeviceMac returns the mac of the device currently using the ip, empty
+// when there is none.
+func (m *Model) getDeviceMac(ip string) (string, error) {
+	var mac string
+	err := m.db.View(func(txn *badger.Txn) error {
+		item, innerError := txn.Get(deviceIpKey(ip))
+		if innerError != nil {
+			return innerError
+		}
+
+		valCopy, innerError := item.ValueCopy(nil)
+		mac = string(valCopy)
+		return innerError
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return "", nil
+	}
+
+	return mac, err
+}
+
+func getDevice(txn *badger.Txn, mac string) (*Device, error) {
+	item, err := txn.Get(deviceKey(mac))
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+
+			return nil, DeviceNotFoundErr
+		}
+		return nil, err
+	}
+
+	valCopy, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return decode[Device](valCopy)
+}
+
+func devicePrefix() []byte {
+	return []byte("devices-")
+}
+
+func deviceKey(mac string) []byte {
+	return append(devicePrefix(), []byte(mac)...)
+}
+
+func deviceIpKey(ip string) []byte {
+	return []byte("device-ips-" + ip)
+}
+
+func deviceActionKey(mac string) []byte {
+	return []byte("device-actions-" + mac)
+}
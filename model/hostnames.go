@@ -0,0 +1,139 @@
+package model
+
+import (
+	"errors"
+	"sort"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+const (
+	DefaultHostnameScoresLimit = 100
+	MaxHostnameScoresLimit     = 1000
+)
+
+// HostnameScore tells how likely a hostname was generated by an algorithm,
+// the features are computed on its registrable label.
+type HostnameScore struct {
+	Hostname        string  `json:"hostname"`
+	Label           string  `json:"label"`
+	Length          int     `json:"length"`
+	Entropy         float64 `json:"entropy"`
+	NgramLikelihood float64 `json:"ngramLikelihood"`
+	DigitRatio      float64 `json:"digitRatio"`
+	Score           float64 `json:"score"`
+	Suspicious      bool    `json:"suspicious"`
+}
+
+type HostnameScoresQuery struct {
+	Limit      int
+	MinScore   float64
+	Suspicious *bool
+}
+
+func (q *HostnameScoresQuery) matches(score *HostnameScore) bool {
+	if score.Score < q.MinScore {
+		return false
+	}
+
+	if q.Suspicious != nil && score.Suspicious != *q.Suspicious {
+		return false
+	}
+
+	return true
+}
+
+func (m *Model) StoreHostnameScore(score *HostnameScore) error {
+	bytes, err := encode(*score)
+	if err != nil {
+		return err
+	}
+
+	return m.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(hostnameScoreKey(score.Hostname), bytes)
+	})
+}
+
+func (m *Model) GetHostnameScore(hostname string) (*HostnameScore, error) {
+	var toReturn *HostnameScore
+	err := m.db.View(func(txn *badger.Txn) error {
+		item, innerError := txn.Get(hostnameScoreKey(hostname))
+		if errors.Is(innerError, badger.ErrKeyNotFound) {
+			return HostnameScoreNotFoundErr
+		}
+		if innerError != nil {
+			return innerError
+		}
+
+		valCopy, innerError := item.ValueCopy(nil)
+		if innerError != nil {
+			return innerError
+		}
+
+		toReturn, innerError = decode[HostnameScore](valCopy)
+		return innerError
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+// ListHostnameScores returns the scored hostnames matching the query, highest
+// score first.
+func (m *Model) ListHostnameScores(query *HostnameScoresQuery) ([]*HostnameScore, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultHostnameScoresLimit
+	}
+	if limit > MaxHostnameScoresLimit {
+		limit = MaxHostnameScoresLimit
+	}
+
+	toReturn := make([]*HostnameScore, 0)
+	err := m.db.View(func(txn *badger.Txn) error {
+		iteratorOptions := badger.DefaultIteratorOptions
+		iteratorOptions.Prefix = hostnameScorePrefix()
+		iterator := txn.NewIterator(iteratorOptions)
+		defer iterator.Close()
+
+		for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+			valCopy, innerError := iterator.Item().ValueCopy(nil)
+			if innerError != nil {
+				return innerError
+			}
+
+			score, innerError := decode[HostnameScore](valCopy)
+			if innerError != nil {
+				return innerError
+			}
+
+			if query.matches(score) {
+				toReturn = append(toReturn, score)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(toReturn, func(i, j int) bool {
+		return toReturn[i].Score > toReturn[j].Score
+	})
+	if len(toReturn) > limit {
+		toReturn = toReturn[:limit]
+	}
+
+	return toReturn, nil
+}
+
+func hostnameScorePrefix() []byte {
+	return []byte("hostname-scores-")
+}
+
+func hostnameScoreKey(hostname string) []byte {
+	return append(hostnameScorePrefix(), []byte(hostname)...)
+}
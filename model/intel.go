@@ -0,0 +1,204 @@
+package model
+
+import (
+	"errors"
+	"strings"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// ThreatIntelMatch is an indicator of a threat intelligence feed matching an
+// ip or one of its hostnames.
+type ThreatIntelMatch struct {
+	Feed      string `json:"feed"`
+	Indicator string `json:"indicator"`
+	Type      string `json:"type"`
+	Context   string `json:"context,omitempty"`
+}
+
+// StoreThreatIntel replaces the matches of the ip, they are stored apart from
+// the meta so that feed refreshes can remove them.
+func (m *Model) StoreThreatIntel(ip string, matches []ThreatIntelMatch) error {
+	if len(matches) == 0 {
+		return m.db.Update(func(txn *badger.Txn) error {
+			return txn.Delete(threatIntelKey(ip))
+		})
+	}
+
+	bytes, err := encode(matches)
+	if err != nil {
+		return err
+	}
+
+	return m.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(threatIntelKey(ip), bytes)
+	})
+}
+
+// AddThreatIntel adds the matches the ip does not have yet, like the ones of
+// the hostnames it is reached with.
+func (m *Model) AddThreatIntel(ip string, matches []ThreatIntelMatch) error {
+	return m.db.Update(func(txn *badger.Txn) error {
+		stored, err := getThreatIntel(txn, ip)
+		if err != nil {
+			return err
+		}
+
+		added := false
+		for _, aMatch := range matches {
+			if !containsMatch(stored, aMatch) {
+				stored = append(stored, aMatch)
+				added = true
+			}
+		}
+		if !added {
+			return nil
+		}
+
+		bytes, err := encode(stored)
+		if err != nil {
+			return err
+		}
+
+		return txn.Set(threatIntelKey(ip), bytes)
+	})
+}
+
+// ListThreatIntel returns the matches of every ip having them.
+func (m *Model) ListThreatIntel() (map[string][]ThreatIntelMatch, error) {
+	toReturn := make(map[string][]ThreatIntelMatch)
+	err := m.db.View(func(txn *badger.Txn) error {
+		iteratorOptions := badger.DefaultIteratorOptions
+		iteratorOptions.Prefix = threatIntelKey("")
+		iterator := txn.NewIterator(iteratorOptions)
+		defer iterator.Close()
+
+		for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+			valCopy, innerError := iterator.Item().ValueCopy(nil)
+			if innerError != nil {
+				return innerError
+			}
+
+			matches, innerError := decode[[]ThreatIntelMatch](valCopy)
+			if innerError != nil {
+				return innerError
+			}
+
+			ip := strings.TrimPrefix(string(iterator.Item().Key()), string(threatIntelKey("")))
+			toReturn[ip] = *matches
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+// ListIpHostnames returns the hostnames every ip is known by, resolved or
+// reached with, they are found through the search index of the hostnames.
+func (m *Model) ListIpHostnames() (map[string][]string, error) {
+	toReturn := make(map[string][]string)
+	err := m.db.View(func(txn *badger.Txn) error {
+		iteratorOptions := badger.DefaultIteratorOptions
+		iteratorOptions.Prefix = searchPrefix(HostnameField, "")
+		iterator := txn.NewIterator(iteratorOptions)
+		defer iterator.Close()
+
+		for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+			_, _, ip, ok := parseSearchKey(iterator.Item().Key())
+			if !ok {
+				continue
+			}
+
+			valCopy, innerError := iterator.Item().ValueCopy(nil)
+			if innerError != nil {
+				return innerError
+			}
+
+			toReturn[ip] = append(toReturn[ip], string(valCopy))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+// ListMetas returns the meta of every ip having it, they are found through
+// the search index of the ips.
+func (m *Model) ListMetas() (map[string]*Meta, error) {
+	toReturn := make(map[string]*Meta)
+	err := m.db.View(func(txn *badger.Txn) error {
+		iteratorOptions := badger.DefaultIteratorOptions
+		iteratorOptions.Prefix = searchPrefix(IpField, "")
+		iterator := txn.NewIterator(iteratorOptions)
+		defer iterator.Close()
+
+		for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+			valCopy, innerError := iterator.Item().ValueCopy(nil)
+			if innerError != nil {
+				return innerError
+			}
+
+			ip := string(valCopy)
+			meta, innerError := getMeta(txn, ip)
+			if errors.Is(innerError, IpNotFoundErr) {
+				continue
+			}
+			if innerError != nil {
+				return innerError
+			}
+
+			toReturn[ip] = meta
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func getThreatIntel(txn *badger.Txn, ip string) ([]ThreatIntelMatch, error) {
+	item, err := txn.Get(threatIntelKey(ip))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	valCopy, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := decode[[]ThreatIntelMatch](valCopy)
+	if err != nil {
+		return nil, err
+	}
+
+	return *matches, nil
+}
+
+func containsMatch(matches []ThreatIntelMatch, match ThreatIntelMatch) bool {
+	for _, aMatch := range matches {
+		if aMatch == match {
+			return true
+		}
+	}
+
+	return false
+}
+
+func threatIntelKey(ip string) []byte {
+	return []byte("threat-intel-" + ip)
+}
@@ -0,0 +1,509 @@
+package model
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+const (
+	DefaultIpsLimit = 100
+	MaxIpsLimit     = 1000
+
+	// ipTouchInterval throttles the writes of when an ip was last seen
+	ipTouchInterval = time.Minute
+)
+
+var InvalidCursorErr = errors.New("invalid cursor")
+
+type IpEntry struct {
+	Ip        string    `json:"ip"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	// Labels are resolved when listing, they are not stored with the entry
+	Labels *Labels `json:"labels,omitempty"`
+}
+
+type IpsSortField uint8
+
+const (
+	SortByIp IpsSortField = iota
+	SortByFirstSeen
+	SortByLastSeen
+)
+
+type IpsFilter struct {
+	Cidr               *net.IPNet
+	Country            *string
+	IsCdn              *bool
+	HasVulnerabilities *bool
+	Category           *string
+	Label              *string
+	Owner              *string
+}
+
+type IpsQuery struct {
+	Limit      int
+	Cursor     string
+	SortBy     IpsSortField
+	Descending bool
+	Filter     IpsFilter
+}
+
+type IpsPage struct {
+	Items      []*IpEntry `json:"items"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+// indexPrefix returns the prefix of the keys ordering the ips as requested.
+func (q *IpsQuery) indexPrefix() []byte {
+	switch q.SortBy {
+	case SortByFirstSeen:
+		return ipFirstSeenPrefix()
+	case SortByLastSeen:
+		return ipLastSeenPrefix()
+	default:
+		return ipOrderPrefix()
+	}
+}
+
+// cursors are the index key of the last ip of a page
+func encodeCursor(key []byte) string {
+	return base64.RawURLEncoding.EncodeToString(key)
+}
+
+func decodeCursor(value string, prefix []byte) ([]byte, error) {
+	toReturn, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || !bytes.HasPrefix(toReturn, prefix) {
+		return nil, InvalidCursorErr
+	}
+
+	return toReturn, nil
+}
+
+// ipSortKey orders ipv4 addresses before ipv6 ones, each by their bytes.
+func ipSortKey(ip string) []byte {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return append([]byte{0xfe}, ip...)
+	}
+
+	if v4 := parsed.To4(); v4 != nil {
+		return append([]byte{4}, v4...)
+	}
+
+	return append([]byte{6}, parsed...)
+}
+
+func (f *IpsFilter) matches(txn *badger.Txn, ip string) (bool, error) {
+	if f.Cidr != nil && !f.Cidr.Contains(net.ParseIP(ip)) {
+		return false, nil
+	}
+
+	if f.Label != nil || f.Owner != nil {
+		labels, err := resolveLabels(txn, ip)
+		if errors.Is(err, LabelsNotFoundErr) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		if !labels.matches(f.Label, f.Owner) {
+			return false, nil
+		}
+	}
+
+	if f.Country == nil && f.IsCdn == nil && f.HasVulnerabilities == nil && f.Category == nil {
+		return true, nil
+	}
+
+	meta, err := getMeta(txn, ip)
+	if errors.Is(err, IpNotFoundErr) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if f.Country != nil && (meta.Country == nil || !strings.EqualFold(*meta.Country, *f.Country)) {
+		return false, nil
+	}
+
+	if f.IsCdn != nil && (meta.IsCdn != nil && *meta.IsCdn) != *f.IsCdn {
+		return false, nil
+	}
+
+	if f.HasVulnerabilities != nil && (len(meta.Vulnerabilities) > 0) != *f.HasVulnerabilities {
+		return false, nil
+	}
+
+	if f.Category != nil && (meta.Category == nil || !strings.EqualFold(*meta.Category, *f.Category)) {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// ListIps returns a page of the ips matching the query, following the index
+// of the requested order from the cursor.
+func (m *Model) ListIps(query *IpsQuery) (*IpsPage, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultIpsLimit
+	}
+	if limit > MaxIpsLimit {
+		limit = MaxIpsLimit
+	}
+
+	var after []byte
+	if query.Cursor != "" {
+		decoded, err := decodeCursor(query.Cursor, query.indexPrefix())
+		if err != nil {
+			return nil, err
+		}
+		after = decoded
+	}
+
+	toReturn := &IpsPage{
+		Items: make([]*IpEntry, 0, limit),
+	}
+	err := m.db.View(func(txn *badger.Txn) error {
+		return scanIps(txn, query, after, func(key []byte, entry *IpEntry) (bool, error) {
+			if len(toReturn.Items) == limit {
+				toReturn.NextCursor = encodeCursor(after)
+				return false, nil
+			}
+
+			labels, innerError := resolveLabels(txn, entry.Ip)
+			if innerError != nil && !errors.Is(innerError, LabelsNotFoundErr) {
+				return false, innerError
+			}
+			entry.Labels = labels
+
+			toReturn.Items = append(toReturn.Items, entry)
+			after = key
+			return true, nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+// ListAllIps returns every ip matching the query, in the requested order.
+func (m *Model) ListAllIps(query *IpsQuery) ([]string, error) {
+	toReturn := make([]string, 0)
+	err := m.db.View(func(txn *badger.Txn) error {
+		return scanIps(txn, query, nil, func(_ []byte, entry *IpEntry) (bool, error) {
+			toReturn = append(toReturn, entry.Ip)
+			return true, nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+// scanIps calls found with the ips matching the filter of the query, in the
+// order of its index, after the given index key, until found returns false.
+func scanIps(txn *badger.Txn, query *IpsQuery, after []byte, found func(key []byte, entry *IpEntry) (bool, error)) error {
+	prefix := query.indexPrefix()
+	iteratorOptions := badger.DefaultIteratorOptions
+	iteratorOptions.Prefix = prefix
+	iteratorOptions.Reverse = query.Descending
+	iterator := txn.NewIterator(iteratorOptions)
+	defer iterator.Close()
+
+	switch {
+	case after != nil:
+		iterator.Seek(after)
+		if iterator.Valid() && bytes.Equal(iterator.Item().Key(), after) {
+			iterator.Next()
+		}
+	case query.Descending:
+		iterator.Seek(append(prefix, 0xff))
+	default:
+		iterator.Rewind()
+	}
+
+	for ; iterator.Valid(); iterator.Next() {
+		ip, err := iterator.Item().ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+
+		matches, err := query.Filter.matches(txn, string(ip))
+		if err != nil {
+			return err
+		}
+		if !matches {
+			continue
+		}
+
+		entry, err := getIpEntry(txn, string(ip))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		next, err := found(iterator.Item().KeyCopy(nil), entry)
+		if err != nil || !next {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getIpEntry(txn *badger.Txn, ip string) (*IpEntry, error) {
+	item, err := txn.Get(ipEntryKey(ip))
+	if err != nil {
+		return nil, err
+	}
+
+	valCopy, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return decode[IpEntry](valCopy)
+}
+
+// touchIp stores when the ip was seen, at most once per ipTouchInterval.
+func (m *Model) touchIp(ip string, seenAt time.Time) error {
+	if lastTouched, ok := m.touched.Get(ip); ok && seenAt.Sub(lastTouched.(time.Time)) < ipTouchInterval {
+		return nil
+	}
+
+	m.ipsMutex.Lock()
+	defer m.ipsMutex.Unlock()
+
+	err := m.db.Update(func(txn *badger.Txn) error {
+		entry, innerError := getIpEntry(txn, ip)
+		if errors.Is(innerError, badger.ErrKeyNotFound) {
+			entry = &IpEntry{
+				Ip:        ip,
+				FirstSeen: seenAt,
+			}
+			if innerError := txn.Set(ipOrderKey(ip), []byte(ip)); innerError != nil {
+				return innerError
+			}
+			if innerError := txn.Set(ipFirstSeenKey(ip, seenAt), []byte(ip)); innerError != nil {
+				return innerError
+			}
+		} else if innerError != nil {
+			return innerError
+		} else if innerError := txn.Delete(ipLastSeenKey(ip, entry.LastSeen)); innerError != nil {
+			return innerError
+		}
+
+		entry.LastSeen = seenAt
+
+		bytes, innerError := encode(*entry)
+		if innerError != nil {
+			return innerError
+		}
+
+		if innerError := txn.Set(ipLastSeenKey(ip, seenAt), []byte(ip)); innerError != nil {
+			return innerError
+		}
+
+		return txn.Set(ipEntryKey(ip), bytes)
+	})
+	if err != nil {
+		return err
+	}
+
+	m.touched.Add(ip, seenAt)
+	return nil
+}
+
+// migrateIpsSet moves the ips stored as a single set, as older versions did,
+// to one key per ip.
+func (m *Model) migrateIpsSet() error {
+	missing := make([]string, 0)
+	found := false
+	err := m.db.View(func(txn *badger.Txn) error {
+		item, innerError := txn.Get(ipsKey())
+		if errors.Is(innerError, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if innerError != nil {
+			return innerError
+		}
+		found = true
+
+		valCopy, innerError := item.ValueCopy(nil)
+		if innerError != nil {
+			return innerError
+		}
+
+		decoded, innerError := decode[set](valCopy)
+		if innerError != nil {
+			return innerError
+		}
+
+		for k := range *decoded {
+			ip := k.(string)
+			if _, innerError := txn.Get(ipEntryKey(ip)); errors.Is(innerError, badger.ErrKeyNotFound) {
+				missing = append(missing, ip)
+			} else if innerError != nil {
+				return innerError
+			}
+		}
+
+		return nil
+	})
+	if err != nil || !found {
+		return err
+	}
+
+	m.logger.Log.Infof("Migrating %d ips to single keys", len(missing))
+	batch := m.db.NewWriteBatch()
+	defer batch.Cancel()
+
+	for _, ip := range missing {
+		bytes, err := encode(IpEntry{
+			Ip: ip,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := batch.Set(ipEntryKey(ip), bytes); err != nil {
+			return err
+		}
+	}
+
+	if err := batch.Delete(ipsKey()); err != nil {
+		return err
+	}
+
+	return batch.Flush()
+}
+
+// indexIpEntries adds the order keys of the ips stored before they existed.
+func (m *Model) indexIpEntries() error {
+	indexed := false
+	entries := make([]*IpEntry, 0)
+	err := m.db.View(func(txn *badger.Txn) error {
+		_, innerError := txn.Get(ipsIndexedKey())
+		if innerError == nil {
+			indexed = true
+			return nil
+		}
+		if !errors.Is(innerError, badger.ErrKeyNotFound) {
+			return innerError
+		}
+
+		iterator := txn.NewIterator(badger.IteratorOptions{Prefix: ipEntryPrefix()})
+		defer iterator.Close()
+
+		for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+			// the order keys share the prefix
+			if net.ParseIP(string(bytes.TrimPrefix(iterator.Item().Key(), ipEntryPrefix()))) == nil {
+				continue
+			}
+
+			valCopy, innerError := iterator.Item().ValueCopy(nil)
+			if innerError != nil {
+				return innerError
+			}
+
+			entry, innerError := decode[IpEntry](valCopy)
+			if innerError != nil {
+				return innerError
+			}
+			entries = append(entries, entry)
+		}
+
+		return nil
+	})
+	if err != nil || indexed {
+		return err
+	}
+
+	m.logger.Log.Infof("Indexing the order of %d ips", len(entries))
+	batch := m.db.NewWriteBatch()
+	defer batch.Cancel()
+
+	for _, anEntry := range entries {
+		if err := batch.Set(ipOrderKey(anEntry.Ip), []byte(anEntry.Ip)); err != nil {
+			return err
+		}
+		if err := batch.Set(ipFirstSeenKey(anEntry.Ip, anEntry.FirstSeen), []byte(anEntry.Ip)); err != nil {
+			return err
+		}
+		if err := batch.Set(ipLastSeenKey(anEntry.Ip, anEntry.LastSeen), []byte(anEntry.Ip)); err != nil {
+			return err
+		}
+	}
+
+	if err := batch.Set(ipsIndexedKey(), []byte{}); err != nil {
+		return err
+	}
+
+	return batch.Flush()
+}
+
+func ipEntryPrefix() []byte {
+	return []byte("ips-")
+}
+
+func ipEntryKey(ip string) []byte {
+	return append(ipEntryPrefix(), []byte(ip)...)
+}
+
+func ipOrderPrefix() []byte {
+	return []byte("ips-order-")
+}
+
+func ipOrderKey(ip string) []byte {
+	return append(ipOrderPrefix(), ipSortKey(ip)...)
+}
+
+func ipFirstSeenPrefix() []byte {
+	return []byte("ips-first-")
+}
+
+func ipFirstSeenKey(ip string, firstSeen time.Time) []byte {
+	return append(ipFirstSeenPrefix(), ipTimeKey(ip, firstSeen)...)
+}
+
+func ipLastSeenPrefix() []byte {
+	return []byte("ips-last-")
+}
+
+func ipLastSeenKey(ip string, lastSeen time.Time) []byte {
+	return append(ipLastSeenPrefix(), ipTimeKey(ip, lastSeen)...)
+}
+
+// ipTimeKey orders by time then by ip, ips migrated from older versions have
+// no time and come first.
+func ipTimeKey(ip string, at time.Time) []byte {
+	var seconds uint64
+	if at.Unix() > 0 {
+		seconds = uint64(at.Unix())
+	}
+
+	toReturn := binary.BigEndian.AppendUint64(make([]byte, 0, 32), seconds)
+	toReturn = append(toReturn, '|')
+	return append(toReturn, ipSortKey(ip)...)
+}
+
+func ipsIndexedKey() []byte {
+	return []byte("ip-orders-indexed")
+}
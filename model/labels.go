@@ -0,0 +1,206 @@
+package model
+
+import (
+	"errors"
+	"net"
+	"strings"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// Labels are the names and notes users give to ips and devices.
+type Labels struct {
+	Name  string   `json:"name,omitempty"`
+	Owner string   `json:"owner,omitempty"`
+	Notes string   `json:"notes,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+// matches reports whether the name or one of the tags is label, and the owner
+// is owner, ignoring the nil ones.
+func (l *Labels) matches(label *string, owner *string) bool {
+	if label != nil {
+		found := strings.EqualFold(l.Name, *label)
+		for _, aTag := range l.Tags {
+			found = found || strings.EqualFold(aTag, *label)
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return owner == nil || strings.EqualFold(l.Owner, *owner)
+}
+
+func (m *Model) GetIpLabels(ip string) (*Labels, error) {
+	return m.getLabels(ipLabelsKey(ip))
+}
+
+func (m *Model) SetIpLabels(ip string, labels *Labels) error {
+	return m.setLabels(ipLabelsKey(ip), labels)
+}
+
+func (m *Model) DeleteIpLabels(ip string) error {
+	return m.deleteLabels(ipLabelsKey(ip))
+}
+
+func (m *Model) GetDeviceLabels(mac string) (*Labels, error) {
+	return m.getLabels(deviceLabelsKey(mac))
+}
+
+func (m *Model) SetDeviceLabels(mac string, labels *Labels) error {
+	return m.setLabels(deviceLabelsKey(mac), labels)
+}
+
+func (m *Model) DeleteDeviceLabels(mac string) error {
+	return m.deleteLabels(deviceLabelsKey(mac))
+}
+
+// ListLabelledIps returns the ips whose name or one of the tags is label.
+func (m *Model) ListLabelledIps(label string) ([]string, error) {
+	toReturn := make([]string, 0)
+	err := m.db.View(func(txn *badger.Txn) error {
+		iteratorOptions := badger.DefaultIteratorOptions
+		iteratorOptions.Prefix = ipLabelsPrefix()
+		iterator := txn.NewIterator(iteratorOptions)
+		defer iterator.Close()
+
+		for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+			valCopy, innerError := iterator.Item().ValueCopy(nil)
+			if innerError != nil {
+				return innerError
+			}
+
+			labels, innerError := decode[Labels](valCopy)
+			if innerError != nil {
+				return innerError
+			}
+
+			if labels.matches(&label, nil) {
+				toReturn = append(toReturn, strings.TrimPrefix(string(iterator.Item().Key()), string(ipLabelsPrefix())))
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+// ResolveLabels returns the labels of an ip or, when it has none, the labels
+// of the device currently using it.
+func (m *Model) ResolveLabels(ip string) (*Labels, error) {
+	var toReturn *Labels
+	err := m.db.View(func(txn *badger.Txn) error {
+		labels, innerError := resolveLabels(txn, ip)
+		toReturn = labels
+		return innerError
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func resolveLabels(txn *badger.Txn, ip string) (*Labels, error) {
+	labels, err := getLabels(txn, ipLabelsKey(ip))
+	if !errors.Is(err, LabelsNotFoundErr) {
+		return labels, err
+	}
+
+	item, err := txn.Get(deviceIpKey(ip))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, LabelsNotFoundErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	mac, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return getLabels(txn, deviceLabelsKey(string(mac)))
+}
+
+func (m *Model) getLabels(key []byte) (*Labels, error) {
+	var toReturn *Labels
+	err := m.db.View(func(txn *badger.Txn) error {
+		labels, innerError := getLabels(txn, key)
+		toReturn = labels
+		return innerError
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func (m *Model) setLabels(key []byte, labels *Labels) error {
+	bytes, err := encode(*labels)
+	if err != nil {
+		return err
+	}
+
+	return m.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(key, bytes)
+	})
+}
+
+func (m *Model) deleteLabels(key []byte) error {
+	return m.db.Update(func(txn *badger.Txn) error {
+		if _, err := txn.Get(key); err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return LabelsNotFoundErr
+			}
+			return err
+		}
+
+		return txn.Delete(key)
+	})
+}
+
+func getLabels(txn *badger.Txn, key []byte) (*Labels, error) {
+	item, err := txn.Get(key)
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+
+			return nil, LabelsNotFoundErr
+		}
+		return nil, err
+	}
+
+	valCopy, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return decode[Labels](valCopy)
+}
+
+func ipLabelsPrefix() []byte {
+	return []byte("labels-ip-")
+}
+
+// ipLabelsKey uses the canonical form of the ip, so that ::ffff:10.0.0.1 and
+// 10.0.0.1 share their labels.
+func ipLabelsKey(ip string) []byte {
+	if parsed := net.ParseIP(ip); parsed != nil {
+		ip = parsed.String()
+	}
+
+	return append(ipLabelsPrefix(), []byte(ip)...)
+}
+
+func deviceLabelsKey(mac string) []byte {
+	return []byte("labels-mac-" + mac)
+}
@@ -3,22 +3,38 @@ package model
 import (
 	"bytes"
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
 	"time"
 
 	badger "github.com/dgraph-io/badger/v4"
+	lru "github.com/hashicorp/golang-lru"
 
 	logFacility "auditor/logger"
+	"auditor/metrics"
 )
 
+// throttledKeys bounds the keys remembered to throttle their writes.
+const throttledKeys = 64 * 1024
+
 type ModelConfigurations struct {
 	PathWhereStoreDabaseFile *string
 	ApplicationName          *string
 	ModelMergersTime         time.Duration
+	LearningPeriod           *time.Duration
 }
 
+const (
+	TorCategory     = "tor"
+	VpnCategory     = "vpn"
+	HostingCategory = "hosting"
+	CdnCategory     = "cdn"
+)
+
+var Categories = []string{TorCategory, VpnCategory, HostingCategory, CdnCategory}
+
 type Meta struct {
 	Hostnames       []string `json:"hostnames,omitempty"`
 	Isp             *string  `json:"isp,omitempty"`
@@ -29,19 +45,34 @@ type Meta struct {
 	Vulnerabilities []string `json:"vulnerabilities,omitempty"`
 	IsCdn           *bool    `json:"isCdn,omitempty"`
 	Cdn             *string  `json:"cdn,omitempty"`
+	VendorClass     *string  `json:"vendorClass,omitempty"`
+	Os              *string  `json:"os,omitempty"`
+	DeviceType      *string  `json:"deviceType,omitempty"`
+	Category        *string  `json:"category,omitempty"`
+	Provider        *string  `json:"provider,omitempty"`
+
+	ThreatIntel []ThreatIntelMatch `json:"threatIntel,omitempty"`
 }
 
 type Action struct {
-	SrcAddr  *string
-	DstAddr  *string
-	Hostname *string
-	SrcPort  *uint16
-	DstPort  *uint16
+	SrcAddr  *string    `json:"srcAddr,omitempty"`
+	DstAddr  *string    `json:"dstAddr,omitempty"`
+	Hostname *string    `json:"hostname,omitempty"`
+	SrcPort  *uint16    `json:"srcPort,omitempty"`
+	DstPort  *uint16    `json:"dstPort,omitempty"`
+	Exporter *string    `json:"exporter,omitempty"`
+	Time     *time.Time `json:"time,omitempty"`
+	// Tracker is the ad or tracker domain listed for the hostname or the
+	// destination, set during enrichment
+	Tracker *string `json:"tracker,omitempty"`
 }
 
 type ActionsByIp struct {
 	Ip      *string
 	Traffic map[string][]string
+	// Exporters are the observation points that reported the traffic of each
+	// destination
+	Exporters map[string][]string
 }
 
 type ModelEntity uint8
@@ -49,6 +80,9 @@ type ModelEntity uint8
 const (
 	Ips ModelEntity = iota
 	Actions
+	Devices
+	LabelsEntity
+	HostnameScores
 )
 
 type NotFoundErr struct {
@@ -62,6 +96,15 @@ var (
 	ActionNotFoundErr = &NotFoundErr{
 		Entity: Actions,
 	}
+	DeviceNotFoundErr = &NotFoundErr{
+		Entity: Devices,
+	}
+	LabelsNotFoundErr = &NotFoundErr{
+		Entity: LabelsEntity,
+	}
+	HostnameScoreNotFoundErr = &NotFoundErr{
+		Entity: HostnameScores,
+	}
 )
 
 func (e *NotFoundErr) Error() string {
@@ -78,18 +121,50 @@ type Model struct {
 	configuration *ModelConfigurations
 	db            *badger.DB
 
-	metaMutex      *sync.RWMutex
-	actionsMutex   *sync.RWMutex
-	ipsMergerMutex *sync.RWMutex
-
-	metaMerger    map[string]*badger.MergeOperator
-	actionsMerger map[string]*badger.MergeOperator
-	ipsMerger     *badger.MergeOperator
+	metaMutex       *sync.RWMutex
+	actionsMutex    *sync.RWMutex
+	ipsMutex        *sync.Mutex
+	devicesMutex    *sync.Mutex
+	categoriesMutex *sync.Mutex
+	trackersMutex   *sync.Mutex
+
+	metaMerger          map[string]*badger.MergeOperator
+	actionsMerger       map[string]*badger.MergeOperator
+	deviceActionsMerger map[string]*badger.MergeOperator
+	contactsMerger      map[string]*badger.MergeOperator
+	trackersMerger      map[string]*badger.MergeOperator
+	categoriesMerger    map[string]*categoriesMerger
+	sightings           *lru.Cache
+	indexed             *lru.Cache
+	touched             *lru.Cache
+	destinations        *lru.Cache
+
+	NewDestinations chan *NewDestination
 }
 
 func New(logger *logFacility.Logger, modelConfigurations *ModelConfigurations) (*Model, error) {
 	logger.Log.Debug("Creating data facility")
 
+	sightings, err := lru.New(throttledKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	indexed, err := lru.New(throttledKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	touched, err := lru.New(throttledKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	destinations, err := lru.New(throttledKeys)
+	if err != nil {
+		return nil, err
+	}
+
 	databaseLocation := fmt.Sprintf("%s/%s.data", *modelConfigurations.PathWhereStoreDabaseFile, *modelConfigurations.ApplicationName)
 	badgerOptions := logger.Level.ToBadger(badger.DefaultOptions(databaseLocation), logger)
 	db, err := badger.Open(badgerOptions)
@@ -107,16 +182,44 @@ func New(logger *logFacility.Logger, modelConfigurations *ModelConfigurations) (
 		configuration: modelConfigurations,
 		db:            db,
 
-		metaMutex:      &sync.RWMutex{},
-		actionsMutex:   &sync.RWMutex{},
-		ipsMergerMutex: &sync.RWMutex{},
+		metaMutex:       &sync.RWMutex{},
+		actionsMutex:    &sync.RWMutex{},
+		ipsMutex:        &sync.Mutex{},
+		devicesMutex:    &sync.Mutex{},
+		categoriesMutex: &sync.Mutex{},
+		trackersMutex:   &sync.Mutex{},
+
+		metaMerger:          make(map[string]*badger.MergeOperator),
+		actionsMerger:       make(map[string]*badger.MergeOperator),
+		deviceActionsMerger: make(map[string]*badger.MergeOperator),
+		contactsMerger:      make(map[string]*badger.MergeOperator),
+		trackersMerger:      make(map[string]*badger.MergeOperator),
+		categoriesMerger:    make(map[string]*categoriesMerger),
+		sightings:           sightings,
+		indexed:             indexed,
+		touched:             touched,
+		destinations:        destinations,
 
-		metaMerger:    make(map[string]*badger.MergeOperator),
-		actionsMerger: make(map[string]*badger.MergeOperator),
+		NewDestinations: make(chan *NewDestination, 100),
 	}
 
-	toReturn.ipsMerger = db.GetMergeOperator(ipsKey(), toReturn.mergeIps, modelConfigurations.ModelMergersTime)
+	if err := toReturn.migrateIpsSet(); err != nil {
+		return nil, err
+	}
 
+	if err := toReturn.indexIpEntries(); err != nil {
+		return nil, err
+	}
+
+	if err := toReturn.indexExisting(); err != nil {
+		return nil, err
+	}
+
+	if err := toReturn.seedSeenDestinations(); err != nil {
+		return nil, err
+	}
+
+	metrics.RegisterDatabaseSize(db.Size)
 	go toReturn.gc()
 
 	return toReturn, nil
@@ -141,6 +244,28 @@ func (m *Model) Dispose() error {
 		value.Stop()
 	}
 
+	for mac, value := range m.deviceActionsMerger {
+		m.logger.Log.Debugf("Stopping device actions merger for %s", mac)
+		value.Stop()
+	}
+
+	for ip, value := range m.contactsMerger {
+		m.logger.Log.Debugf("Stopping contacts merger for %s", ip)
+		value.Stop()
+	}
+
+	m.trackersMutex.Lock()
+	defer m.trackersMutex.Unlock()
+	for mac, value := range m.trackersMerger {
+		m.logger.Log.Debugf("Stopping trackers merger for %s", mac)
+		value.Stop()
+	}
+
+	m.categoriesMutex.Lock()
+	defer m.categoriesMutex.Unlock()
+	m.logger.Log.Debug("Stopping categories mergers")
+	m.expireCategoriesMergers(time.Now().Add(CategoriesBucket))
+
 	err := m.db.Close()
 	if err != nil {
 
@@ -150,61 +275,35 @@ func (m *Model) Dispose() error {
 	return nil
 }
 
-func (m *Model) Get() ([]string, error) {
-	var valCopy []byte
-	err := m.db.View(func(txn *badger.Txn) error {
-		item, innerError := txn.Get(ipsKey())
-		if innerError != nil {
-			return innerError
-		}
+func (m *Model) Health() error {
+	if m.db.IsClosed() {
+		return errors.New("database is closed")
+	}
 
-		valCopy, innerError = item.ValueCopy(nil)
+	return nil
+}
+
+func (m *Model) GetMeta(ip string) (*Meta, error) {
+	var toReturn *Meta
+	err := m.db.View(func(txn *badger.Txn) error {
+		meta, innerError := getMeta(txn, ip)
 		if innerError != nil {
 			return innerError
 		}
 
+		toReturn = meta
 		return nil
 	})
 
 	if err != nil {
-		if err.Error() == errKeyNotFoundStr {
-
-			return []string{}, nil
-		}
-
 		return nil, err
 	}
 
-	decodedMeta, decodeErr := decode[set](valCopy)
-	if decodeErr != nil {
-
-		return nil, decodeErr
-	}
-
-	toReturn := make([]string, 0, len(*decodedMeta))
-	for k := range *decodedMeta {
-		toReturn = append(toReturn, k.(string))
-	}
-
 	return toReturn, nil
 }
 
-func (m *Model) GetMeta(ip string) (*Meta, error) {
-	var valCopy []byte
-	err := m.db.View(func(txn *badger.Txn) error {
-		item, innerError := txn.Get(metaKey(ip))
-		if innerError != nil {
-			return innerError
-		}
-
-		valCopy, innerError = item.ValueCopy(nil)
-		if innerError != nil {
-			return innerError
-		}
-
-		return nil
-	})
-
+func getMeta(txn *badger.Txn, ip string) (*Meta, error) {
+	item, err := txn.Get(metaKey(ip))
 	if err != nil {
 		if err.Error() == errKeyNotFoundStr {
 
@@ -213,13 +312,22 @@ func (m *Model) GetMeta(ip string) (*Meta, error) {
 		return nil, err
 	}
 
-	decodedMeta, decodeErr := decode[Meta](valCopy)
-	if decodeErr != nil {
+	valCopy, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
 
-		return nil, decodeErr
+	meta, err := decode[Meta](valCopy)
+	if err != nil {
+		return nil, err
 	}
 
-	return decodedMeta, nil
+	meta.ThreatIntel, err = getThreatIntel(txn, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	return meta, nil
 }
 
 func (m *Model) GetActions(ip string) (*ActionsByIp, error) {
@@ -239,6 +347,10 @@ func (m *Model) GetActions(ip string) (*ActionsByIp, error) {
 	})
 
 	if err != nil {
+		if err.Error() == errKeyNotFoundStr {
+
+			return nil, ActionNotFoundErr
+		}
 		return nil, err
 	}
 
@@ -270,20 +382,23 @@ func (m *Model) StoreMeta(ip string, meta *Meta) error {
 	}
 	mergingOperator.Add(bytes)
 
-	return nil
+	return m.indexMeta(ip, meta)
 }
 
 func (m *Model) StoreAction(action *Action) error {
 	var mergingOperator *badger.MergeOperator
 
-	hostnames := []string{*action.Hostname}
+	hostnames := []string{}
+	if action.Hostname != nil {
+		hostnames = append(hostnames, *action.Hostname)
+	}
 
 	m.actionsMutex.Lock()
 	defer m.actionsMutex.Unlock()
 	srcVal, srcMetaKeyIsPresent := m.actionsMerger[*action.SrcAddr]
 	if !srcMetaKeyIsPresent {
 		mergingOperator = m.db.GetMergeOperator(actionKey(*action.SrcAddr), m.mergeActions, 100*time.Millisecond)
-		m.metaMerger[*action.SrcAddr] = mergingOperator
+		m.actionsMerger[*action.SrcAddr] = mergingOperator
 	} else {
 		mergingOperator = srcVal
 	}
@@ -296,26 +411,39 @@ func (m *Model) StoreAction(action *Action) error {
 		Traffic: newTraffic,
 	}
 
+	if action.Exporter != nil {
+		srcActionsByIp.Exporters = map[string][]string{
+			*action.DstAddr: {*action.Exporter},
+		}
+	}
+
 	srcBytes, err := encode(*srcActionsByIp)
 	if err != nil {
 		return err
 	}
 
 	mergingOperator.Add(srcBytes)
+	metrics.ActionsStored.Inc()
 
-	m.ipsMergerMutex.Lock()
-	defer m.ipsMergerMutex.Unlock()
+	if err := m.storeDeviceAction(*action.SrcAddr, srcBytes); err != nil {
+		return err
+	}
 
-	setToStore := make(set, 1)
-	setToStore[*action.SrcAddr] = setElement
-	srcIps, errIps := encode(setToStore)
-	if errIps != nil {
+	if err := m.storeContact(action); err != nil {
 		return err
 	}
 
-	m.ipsMerger.Add(srcIps)
+	if err := m.recordNewDestination(*action.SrcAddr, *action.DstAddr, hostnames); err != nil {
+		m.logger.Log.Warnf("Error recording the destination %s of %s: %v", *action.DstAddr, *action.SrcAddr, err)
+	}
 
-	return nil
+	if action.Hostname != nil {
+		if err := m.indexHostname(*action.DstAddr, *action.Hostname); err != nil {
+			return err
+		}
+	}
+
+	return m.touchIp(*action.SrcAddr, time.Now())
 }
 
 const errKeyNotFoundStr = "Key not found"
@@ -348,6 +476,7 @@ func (m *Model) gc() {
 
 func (m *Model) mergeMeta(originalValue, newValue []byte) []byte {
 	m.logger.Log.Debugf("Merging meta values")
+	metrics.Merges.WithLabelValues("meta").Inc()
 	originalMeta, originalMetaErr := decode[Meta](originalValue)
 	newMeta, newMetaErr := decode[Meta](newValue)
 	if originalMetaErr != nil || newMetaErr != nil {
@@ -384,6 +513,26 @@ func (m *Model) mergeMeta(originalValue, newValue []byte) []byte {
 	}
 	originalMeta.Hostnames = newHostnames
 
+	if newMeta.VendorClass != nil {
+		originalMeta.VendorClass = newMeta.VendorClass
+	}
+
+	if newMeta.Os != nil {
+		originalMeta.Os = newMeta.Os
+	}
+
+	if newMeta.DeviceType != nil {
+		originalMeta.DeviceType = newMeta.DeviceType
+	}
+
+	if newMeta.Category != nil {
+		originalMeta.Category = newMeta.Category
+	}
+
+	if newMeta.Provider != nil {
+		originalMeta.Provider = newMeta.Provider
+	}
+
 	m.logger.Log.Debugf("Meta values merged, encoding now")
 	newBytes, encodingErr := encode(originalMeta)
 	if encodingErr != nil {
@@ -396,36 +545,15 @@ func (m *Model) mergeMeta(originalValue, newValue []byte) []byte {
 
 func (m *Model) mergeActions(originalValue, newValue []byte) []byte {
 	m.logger.Log.Debugf("Merging actions values")
+	metrics.Merges.WithLabelValues("actions").Inc()
 	originalDecoded, originalDecodeErr := decode[ActionsByIp](originalValue)
 	newDecoded, newDecodeErr := decode[ActionsByIp](newValue)
 	if originalDecodeErr != nil || newDecodeErr != nil {
 		return originalValue
 	}
 
-	for key, value := range newDecoded.Traffic {
-
-		oldTrafficValue, isTrafficPresent := originalDecoded.Traffic[key]
-		if isTrafficPresent {
-			newHostnamesSet := make(set, len(oldTrafficValue)+len(value))
-			for _, value := range oldTrafficValue {
-				newHostnamesSet[value] = setElement
-			}
-
-			for _, value := range value {
-				newHostnamesSet[value] = setElement
-			}
-
-			newHostnames := make([]string, 0, len(oldTrafficValue)+len(value))
-			for k := range newHostnamesSet {
-				newHostnames = append(newHostnames, k.(string))
-			}
-
-			originalDecoded.Traffic[key] = newHostnames
-		} else {
-
-			originalDecoded.Traffic[key] = value
-		}
-	}
+	originalDecoded.Traffic = mergeTraffic(originalDecoded.Traffic, newDecoded.Traffic)
+	originalDecoded.Exporters = mergeTraffic(originalDecoded.Exporters, newDecoded.Exporters)
 
 	m.logger.Log.Debugf("Actions values merged, encoding now")
 	newBytes, encodingErr := encode(originalDecoded)
@@ -437,26 +565,39 @@ func (m *Model) mergeActions(originalValue, newValue []byte) []byte {
 	return newBytes
 }
 
-func (m *Model) mergeIps(originalValue, newValue []byte) []byte {
-	m.logger.Log.Debugf("Merging ips")
-	originalDecoded, originalDecodeErr := decode[set](originalValue)
-	newDecoded, newDecodeErr := decode[set](newValue)
-	if originalDecodeErr != nil || newDecodeErr != nil {
-		return originalValue
+// mergeTraffic adds the values of the new traffic to the original one, per
+// destination.
+func mergeTraffic(originalTraffic, newTraffic map[string][]string) map[string][]string {
+	if originalTraffic == nil {
+		originalTraffic = make(map[string][]string, len(newTraffic))
 	}
 
-	for k := range *newDecoded {
-		(*originalDecoded)[k] = setElement
-	}
+	for key, value := range newTraffic {
 
-	m.logger.Log.Debugf("Ips merged, encoding now")
-	newBytes, encodingErr := encode(originalDecoded)
-	if encodingErr != nil {
-		return originalValue
+		oldTrafficValue, isTrafficPresent := originalTraffic[key]
+		if isTrafficPresent {
+			newValuesSet := make(set, len(oldTrafficValue)+len(value))
+			for _, value := range oldTrafficValue {
+				newValuesSet[value] = setElement
+			}
+
+			for _, value := range value {
+				newValuesSet[value] = setElement
+			}
+
+			newValues := make([]string, 0, len(oldTrafficValue)+len(value))
+			for k := range newValuesSet {
+				newValues = append(newValues, k.(string))
+			}
+
+			originalTraffic[key] = newValues
+		} else {
+
+			originalTraffic[key] = value
+		}
 	}
-	m.logger.Log.Debugf("Ips values encoded")
 
-	return newBytes
+	return originalTraffic
 }
 
 func metaKey(ip string) []byte {
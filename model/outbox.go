@@ -0,0 +1,102 @@
+package model
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// Notification is an alert waiting to be delivered to a sink, kept in the
+// database so that it survives restarts.
+type Notification struct {
+	Id          []byte
+	Sink        string
+	Alert       *Alert
+	Attempts    int
+	NextAttempt time.Time
+	LastError   string
+}
+
+func (m *Model) EnqueueNotification(notification *Notification) error {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	notification.Id = binary.BigEndian.AppendUint64(make([]byte, 0, 16), uint64(time.Now().UnixNano()))
+	notification.Id = append(notification.Id, id...)
+
+	return m.UpdateNotification(notification)
+}
+
+func (m *Model) UpdateNotification(notification *Notification) error {
+	bytes, err := encode(*notification)
+	if err != nil {
+		return err
+	}
+
+	return m.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(outboxKey(notification.Id), bytes)
+	})
+}
+
+func (m *Model) DeleteNotification(notification *Notification) error {
+	return m.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(outboxKey(notification.Id))
+	})
+}
+
+// PendingNotifications returns, per sink, up to its limit notifications due
+// before the given time, oldest first. Sinks missing from the limits, which are
+// not configured anymore, get up to otherSinksLimit notifications.
+func (m *Model) PendingNotifications(due time.Time, limits map[string]int, otherSinksLimit int) (map[string][]*Notification, error) {
+	toReturn := make(map[string][]*Notification)
+	err := m.db.View(func(txn *badger.Txn) error {
+		iteratorOptions := badger.DefaultIteratorOptions
+		iteratorOptions.Prefix = outboxPrefix()
+		iterator := txn.NewIterator(iteratorOptions)
+		defer iterator.Close()
+
+		for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+			valCopy, innerError := iterator.Item().ValueCopy(nil)
+			if innerError != nil {
+				return innerError
+			}
+
+			notification, innerError := decode[Notification](valCopy)
+			if innerError != nil {
+				return innerError
+			}
+
+			if notification.NextAttempt.After(due) {
+				continue
+			}
+
+			limit, ok := limits[notification.Sink]
+			if !ok {
+				limit = otherSinksLimit
+			}
+			if len(toReturn[notification.Sink]) >= limit {
+				continue
+			}
+
+			toReturn[notification.Sink] = append(toReturn[notification.Sink], notification)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func outboxPrefix() []byte {
+	return []byte("outbox-")
+}
+
+func outboxKey(id []byte) []byte {
+	return append(outboxPrefix(), id...)
+}
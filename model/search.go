@@ -0,0 +1,407 @@
+package model
+
+import (
+	"bytes"
+	"net"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+const DefaultSearchLimit = 100
+
+type SearchField string
+
+const (
+	HostnameField     SearchField = "hostname"
+	IpField           SearchField = "ip"
+	IspField          SearchField = "isp"
+	OrganizationField SearchField = "organization"
+	CveField          SearchField = "cve"
+)
+
+type SearchResult struct {
+	Ip    string      `json:"ip"`
+	Field SearchField `json:"field"`
+	Value string      `json:"value"`
+}
+
+const (
+	searchKeySeparator = "|"
+
+	// searchRefreshInterval throttles the writes of the index, entries not
+	// written again within searchRetention expire
+	searchRefreshInterval = time.Hour
+	searchRetention       = 90 * 24 * time.Hour
+)
+
+func (m *Model) indexMeta(ip string, meta *Meta) error {
+	keys := make(map[string]string)
+	addSearchKey(keys, IpField, ip, ip, ip)
+
+	for _, hostname := range meta.Hostnames {
+		addSearchKey(keys, HostnameField, reverseHostname(hostname), ip, hostname)
+	}
+
+	if meta.Isp != nil {
+		addTokensKeys(keys, IspField, *meta.Isp, ip)
+	}
+
+	if meta.Organization != nil {
+		addTokensKeys(keys, OrganizationField, *meta.Organization, ip)
+	}
+
+	for _, cve := range meta.Vulnerabilities {
+		addSearchKey(keys, CveField, strings.ToLower(cve), ip, cve)
+	}
+
+	return m.setSearchKeys(keys)
+}
+
+func (m *Model) indexHostname(ip string, hostname string) error {
+	keys := make(map[string]string, 1)
+	addSearchKey(keys, HostnameField, reverseHostname(hostname), ip, hostname)
+
+	return m.setSearchKeys(keys)
+}
+
+// setSearchKeys writes the keys that were not written within the refresh
+// interval, in a single transaction.
+func (m *Model) setSearchKeys(keys map[string]string) error {
+	now := time.Now()
+	toSet := make(map[string]string, len(keys))
+	for key, value := range keys {
+		if lastSet, ok := m.indexed.Get(key); ok && now.Sub(lastSet.(time.Time)) < searchRefreshInterval {
+			continue
+		}
+		toSet[key] = value
+	}
+
+	if len(toSet) == 0 {
+		return nil
+	}
+
+	err := m.db.Update(func(txn *badger.Txn) error {
+		for key, value := range toSet {
+			if err := txn.SetEntry(badger.NewEntry([]byte(key), []byte(value)).WithTTL(searchRetention)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for key := range toSet {
+		m.indexed.Add(key, now)
+	}
+
+	return nil
+}
+
+// indexExisting builds the search index for data stored before the index
+// existed. It runs once, a marker key records that it has been done.
+func (m *Model) indexExisting() error {
+	alreadyIndexed := false
+	err := m.db.View(func(txn *badger.Txn) error {
+		_, innerError := txn.Get(searchIndexedKey())
+		alreadyIndexed = innerError == nil
+		return nil
+	})
+	if err != nil || alreadyIndexed {
+		return err
+	}
+
+	metas := make(map[string]*Meta)
+	traffic := make(map[string][]string)
+	err = m.db.View(func(txn *badger.Txn) error {
+		iterator := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer iterator.Close()
+
+		for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+			key := string(iterator.Item().Key())
+			if !strings.HasSuffix(key, "-meta") && !strings.HasSuffix(key, "-action") {
+				continue
+			}
+
+			valCopy, innerError := iterator.Item().ValueCopy(nil)
+			if innerError != nil {
+				return innerError
+			}
+
+			if ip := strings.TrimSuffix(key, "-meta"); ip != key {
+				if meta, decodeErr := decode[Meta](valCopy); decodeErr == nil {
+					metas[ip] = meta
+				}
+				continue
+			}
+
+			if actions, decodeErr := decode[ActionsByIp](valCopy); decodeErr == nil {
+				for dstAddr, hostnames := range actions.Traffic {
+					traffic[dstAddr] = append(traffic[dstAddr], hostnames...)
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	m.logger.Log.Infof("Indexing %d metas and %d destinations for search", len(metas), len(traffic))
+	for ip, meta := range metas {
+		if err := m.indexMeta(ip, meta); err != nil {
+			return err
+		}
+	}
+
+	for ip, hostnames := range traffic {
+		for _, hostname := range hostnames {
+			if err := m.indexHostname(ip, hostname); err != nil {
+				return err
+			}
+		}
+	}
+
+	return m.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(searchIndexedKey(), []byte{})
+	})
+}
+
+// Search looks the term up in the indexes kept while storing meta and actions.
+// Hostnames are indexed with their labels reversed, so that a suffix like
+// *.tiktokcdn.com becomes a prefix scan.
+func (m *Model) Search(term string, limit int) ([]*SearchResult, error) {
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+
+	term = strings.ToLower(strings.TrimSpace(term))
+	toReturn := make([]*SearchResult, 0)
+	if term == "" {
+		return toReturn, nil
+	}
+
+	err := m.db.View(func(txn *badger.Txn) error {
+		searches := []func(*badger.Txn, string) ([]*SearchResult, error){
+			searchCves,
+			searchIps,
+			searchHostnames,
+			searchTokens(IspField),
+			searchTokens(OrganizationField),
+		}
+
+		seen := make(set)
+		for _, search := range searches {
+			results, err := search(txn, term)
+			if err != nil {
+				return err
+			}
+
+			for _, result := range results {
+				if _, ok := seen[*result]; ok {
+					continue
+				}
+				seen[*result] = setElement
+				toReturn = append(toReturn, result)
+
+				if len(toReturn) >= limit {
+					return nil
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func searchCves(txn *badger.Txn, term string) ([]*SearchResult, error) {
+	if !strings.HasPrefix(term, "cve-") {
+		return nil, nil
+	}
+
+	return scanSearchKeys(txn, searchPrefix(CveField, term), nil)
+}
+
+func searchIps(txn *badger.Txn, term string) ([]*SearchResult, error) {
+	isIpPrefix := strings.IndexFunc(term, func(r rune) bool {
+		return !unicode.Is(unicode.ASCII_Hex_Digit, r) && r != '.' && r != ':'
+	}) == -1
+	if !isIpPrefix {
+		return nil, nil
+	}
+
+	// sources are known from the ip entries, destinations from their meta
+	toReturn, err := scanSearchKeys(txn, searchPrefix(IpField, term), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	iteratorOptions := badger.DefaultIteratorOptions
+	iteratorOptions.PrefetchValues = false
+	iteratorOptions.Prefix = ipEntryKey(term)
+	iterator := txn.NewIterator(iteratorOptions)
+	defer iterator.Close()
+
+	for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+		ip := string(bytes.TrimPrefix(iterator.Item().Key(), ipEntryPrefix()))
+		if net.ParseIP(ip) == nil {
+			continue
+		}
+
+		toReturn = append(toReturn, &SearchResult{
+			Ip:    ip,
+			Field: IpField,
+			Value: ip,
+		})
+	}
+
+	return toReturn, nil
+}
+
+func searchHostnames(txn *badger.Txn, term string) ([]*SearchResult, error) {
+	wildcardSuffix := strings.HasPrefix(term, "*.")
+	suffix := strings.TrimPrefix(term, "*.")
+
+	if strings.ContainsAny(suffix, "*?[") {
+		return scanSearchKeys(txn, searchPrefix(HostnameField, ""), func(indexed string, value string) bool {
+			matched, err := path.Match(term, strings.ToLower(value))
+			return err == nil && matched
+		})
+	}
+
+	reversed := reverseHostname(suffix)
+	return scanSearchKeys(txn, searchPrefix(HostnameField, reversed), func(indexed string, value string) bool {
+		if indexed == reversed {
+			return !wildcardSuffix
+		}
+
+		return strings.HasPrefix(indexed, reversed+".")
+	})
+}
+
+func searchTokens(field SearchField) func(*badger.Txn, string) ([]*SearchResult, error) {
+	return func(txn *badger.Txn, term string) ([]*SearchResult, error) {
+		tokens := tokenize(term)
+		if len(tokens) == 0 {
+			return nil, nil
+		}
+
+		// every result has to match all the words in the term, the first one
+		// drives the scan
+		return scanSearchKeys(txn, searchPrefix(field, tokens[0]), func(indexed string, value string) bool {
+			valueTokens := tokenize(value)
+			for _, token := range tokens[1:] {
+				found := false
+				for _, valueToken := range valueTokens {
+					if strings.HasPrefix(valueToken, token) {
+						found = true
+						break
+					}
+				}
+
+				if !found {
+					return false
+				}
+			}
+
+			return true
+		})
+	}
+}
+
+func scanSearchKeys(txn *badger.Txn, prefix []byte, accept func(indexed string, value string) bool) ([]*SearchResult, error) {
+	toReturn := make([]*SearchResult, 0)
+
+	iteratorOptions := badger.DefaultIteratorOptions
+	iteratorOptions.Prefix = prefix
+	iterator := txn.NewIterator(iteratorOptions)
+	defer iterator.Close()
+
+	for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+		item := iterator.Item()
+		field, indexed, ip, ok := parseSearchKey(item.Key())
+		if !ok {
+			continue
+		}
+
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if accept != nil && !accept(indexed, string(value)) {
+			continue
+		}
+
+		toReturn = append(toReturn, &SearchResult{
+			Ip:    ip,
+			Field: field,
+			Value: string(value),
+		})
+	}
+
+	return toReturn, nil
+}
+
+func addTokensKeys(keys map[string]string, field SearchField, value string, ip string) {
+	for _, token := range tokenize(value) {
+		addSearchKey(keys, field, token, ip, value)
+	}
+}
+
+func addSearchKey(keys map[string]string, field SearchField, indexed string, ip string, value string) {
+	if indexed == "" {
+		return
+	}
+
+	keys[string(searchPrefix(field, indexed))+searchKeySeparator+ip] = value
+}
+
+func parseSearchKey(key []byte) (SearchField, string, string, bool) {
+	parts := strings.SplitN(string(key), "-", 3)
+	if len(parts) != 3 {
+		return "", "", "", false
+	}
+
+	separatorIndex := strings.LastIndex(parts[2], searchKeySeparator)
+	if separatorIndex < 0 {
+		return "", "", "", false
+	}
+
+	return SearchField(parts[1]), parts[2][:separatorIndex], parts[2][separatorIndex+1:], true
+}
+
+func searchPrefix(field SearchField, indexed string) []byte {
+	return []byte(strings.Join([]string{"search", string(field), indexed}, "-"))
+}
+
+func searchIndexedKey() []byte {
+	return []byte("search-indexed")
+}
+
+func reverseHostname(hostname string) string {
+	labels := strings.Split(strings.Trim(strings.ToLower(hostname), "."), ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+
+	return strings.Join(labels, ".")
+}
+
+func tokenize(value string) []string {
+	return strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,302 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"auditor/metrics"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+const DefaultTopTrackers = 20
+
+// TrackerDomain is a listed ad or tracker domain contacted by a device, hits
+// are the actions matching it.
+type TrackerDomain struct {
+	Domain       string   `json:"domain"`
+	Hits         int      `json:"hits"`
+	Hostnames    []string `json:"hostnames"`
+	Destinations []string `json:"destinations"`
+}
+
+type DeviceTrackers struct {
+	Mac  string           `json:"mac"`
+	Hits int              `json:"hits"`
+	Top  []*TrackerDomain `json:"top,omitempty"`
+}
+
+type TrackersSummary struct {
+	Devices []*DeviceTrackers `json:"devices"`
+	Top     []*TrackerDomain  `json:"top"`
+}
+
+// StoreHostnameTracker records the listed domain a hostname matches, removing
+// it when the domain is empty.
+func (m *Model) StoreHostnameTracker(hostname string, domain string) error {
+	return m.db.Update(func(txn *badger.Txn) error {
+		if domain == "" {
+			return txn.Delete(hostnameTrackerKey(hostname))
+		}
+
+		return txn.Set(hostnameTrackerKey(hostname), []byte(domain))
+	})
+}
+
+// GetHostnameTracker returns the listed domain the hostname matches, empty
+// when it matches none.
+func (m *Model) GetHostnameTracker(hostname string) (string, error) {
+	var toReturn string
+	err := m.db.View(func(txn *badger.Txn) error {
+		domain, innerError := getHostnameTracker(txn, hostname)
+		toReturn = domain
+		return innerError
+	})
+
+	return toReturn, err
+}
+
+// RecordTrackerHit counts an action of the ip matching the tracker domain for
+// its device, actions of ips without a device are not counted.
+func (m *Model) RecordTrackerHit(ip string, domain string, hostname string, destination string) error {
+	mac, err := m.getDeviceMac(ip)
+	if err != nil || mac == "" {
+		return err
+	}
+
+	hit := &TrackerDomain{
+		Domain:       domain,
+		Hits:         1,
+		Hostnames:    make([]string, 0, 1),
+		Destinations: []string{destination},
+	}
+	if hostname != "" {
+		hit.Hostnames = append(hit.Hostnames, hostname)
+	}
+
+	bytes, err := encode(map[string]*TrackerDomain{domain: hit})
+	if err != nil {
+		return err
+	}
+
+	m.trackersMutex.Lock()
+	defer m.trackersMutex.Unlock()
+	mergingOperator, ok := m.trackersMerger[mac]
+	if !ok {
+		mergingOperator = m.db.GetMergeOperator(deviceTrackersKey(mac), m.mergeTrackers, 100*time.Millisecond)
+		m.trackersMerger[mac] = mergingOperator
+	}
+
+	mergingOperator.Add(bytes)
+	return nil
+}
+
+// GetDeviceTrackers returns the tracker domains hit by the actions of the
+// device.
+func (m *Model) GetDeviceTrackers(mac string, limit int) (*DeviceTrackers, error) {
+	var toReturn *DeviceTrackers
+	err := m.db.View(func(txn *badger.Txn) error {
+		if _, innerError := getDevice(txn, mac); innerError != nil {
+			return innerError
+		}
+
+		domains, innerError := deviceTrackerDomains(txn, mac)
+		if innerError != nil {
+			return innerError
+		}
+
+		toReturn = &DeviceTrackers{
+			Mac: mac,
+			Top: topTrackers(domains, limit),
+		}
+		for _, aDomain := range domains {
+			toReturn.Hits += aDomain.Hits
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+// SummarizeTrackers returns the hits of every device, most tracked first, and
+// the tracker domains with the most hits across them.
+func (m *Model) SummarizeTrackers(limit int) (*TrackersSummary, error) {
+	devices, err := m.ListDevices(&DevicesFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	toReturn := &TrackersSummary{
+		Devices: make([]*DeviceTrackers, 0, len(devices)),
+	}
+	err = m.db.View(func(txn *badger.Txn) error {
+		all := make(map[string]*TrackerDomain)
+		for _, aDevice := range devices {
+			domains, innerError := deviceTrackerDomains(txn, aDevice.Mac)
+			if innerError != nil {
+				return innerError
+			}
+
+			deviceTrackers := &DeviceTrackers{
+				Mac: aDevice.Mac,
+			}
+			for name, aDomain := range domains {
+				deviceTrackers.Hits += aDomain.Hits
+
+				total, ok := all[name]
+				if !ok {
+					total = &TrackerDomain{Domain: name}
+					all[name] = total
+				}
+				total.Hits += aDomain.Hits
+				total.Hostnames = appendMissing(total.Hostnames, aDomain.Hostnames...)
+				total.Destinations = appendMissing(total.Destinations, aDomain.Destinations...)
+			}
+			toReturn.Devices = append(toReturn.Devices, deviceTrackers)
+		}
+
+		toReturn.Top = topTrackers(all, limit)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(toReturn.Devices, func(i, j int) bool {
+		return toReturn.Devices[i].Hits > toReturn.Devices[j].Hits
+	})
+
+	return toReturn, nil
+}
+
+// ListTrackerHostnames returns the hostnames matching a listed ad or tracker
+// domain.
+func (m *Model) ListTrackerHostnames() ([]string, error) {
+	return m.listHostnames(hostnameTrackerPrefix(), func(string) bool {
+		return true
+	})
+}
+
+func deviceTrackerDomains(txn *badger.Txn, mac string) (map[string]*TrackerDomain, error) {
+	item, err := txn.Get(deviceTrackersKey(mac))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return make(map[string]*TrackerDomain), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	valCopy, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	domains, err := decode[map[string]*TrackerDomain](valCopy)
+	if err != nil {
+		return nil, err
+	}
+
+	return *domains, nil
+}
+
+func (m *Model) mergeTrackers(originalValue, newValue []byte) []byte {
+	metrics.Merges.WithLabelValues("trackers").Inc()
+	originalDecoded, originalDecodeErr := decode[map[string]*TrackerDomain](originalValue)
+	newDecoded, newDecodeErr := decode[map[string]*TrackerDomain](newValue)
+	if originalDecodeErr != nil || newDecodeErr != nil {
+		return originalValue
+	}
+
+	for name, newDomain := range *newDecoded {
+		oldDomain, isDomainPresent := (*originalDecoded)[name]
+		if !isDomainPresent {
+			(*originalDecoded)[name] = newDomain
+			continue
+		}
+
+		oldDomain.Hits += newDomain.Hits
+		oldDomain.Hostnames = appendMissing(oldDomain.Hostnames, newDomain.Hostnames...)
+		oldDomain.Destinations = appendMissing(oldDomain.Destinations, newDomain.Destinations...)
+	}
+
+	newBytes, encodingErr := encode(originalDecoded)
+	if encodingErr != nil {
+		return originalValue
+	}
+
+	return newBytes
+}
+
+func getHostnameTracker(txn *badger.Txn, hostname string) (string, error) {
+	item, err := txn.Get(hostnameTrackerKey(hostname))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	valCopy, err := item.ValueCopy(nil)
+	return string(valCopy), err
+}
+
+func topTrackers(domains map[string]*TrackerDomain, limit int) []*TrackerDomain {
+	if limit <= 0 {
+		limit = DefaultTopTrackers
+	}
+
+	toReturn := make([]*TrackerDomain, 0, len(domains))
+	for _, aDomain := range domains {
+		sort.Strings(aDomain.Hostnames)
+		sort.Strings(aDomain.Destinations)
+		toReturn = append(toReturn, aDomain)
+	}
+
+	sort.Slice(toReturn, func(i, j int) bool {
+		if toReturn[i].Hits != toReturn[j].Hits {
+			return toReturn[i].Hits > toReturn[j].Hits
+		}
+		return toReturn[i].Domain < toReturn[j].Domain
+	})
+	if len(toReturn) > limit {
+		toReturn = toReturn[:limit]
+	}
+
+	return toReturn
+}
+
+func appendMissing(values []string, newValues ...string) []string {
+	for _, aNewValue := range newValues {
+		present := false
+		for _, aValue := range values {
+			if aValue == aNewValue {
+				present = true
+				break
+			}
+		}
+
+		if !present {
+			values = append(values, aNewValue)
+		}
+	}
+
+	return values
+}
+
+func hostnameTrackerPrefix() []byte {
+	return []byte("hostname-trackers-")
+}
+
+func hostnameTrackerKey(hostname string) []byte {
+	return append(hostnameTrackerPrefix(), []byte(hostname)...)
+}
+
+func deviceTrackersKey(mac string) []byte {
+	return []byte(fmt.Sprintf("device-trackers-%s", mac))
+}
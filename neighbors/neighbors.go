@@ -0,0 +1,99 @@
+package neighbors
+
+import (
+	"auditor/model"
+	"net"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// FromPacket returns the mac to ip associations a packet proves: the sender of
+// ARP and NDP messages and the source of ethernet frames carrying local
+// addresses. Frames from routed addresses carry the router mac, so they are
+// ignored.
+func FromPacket(packet gopacket.Packet) []*model.Sighting {
+	ethernetLayer := packet.Layer(layers.LayerTypeEthernet)
+	if ethernetLayer == nil {
+
+		return nil
+	}
+	ethernet, _ := ethernetLayer.(*layers.Ethernet)
+
+	if arpLayer := packet.Layer(layers.LayerTypeARP); arpLayer != nil {
+		arp, _ := arpLayer.(*layers.ARP)
+
+		return sightingsOf(net.HardwareAddr(arp.SourceHwAddress), net.IP(arp.SourceProtAddress))
+	}
+
+	if solicitationLayer := packet.Layer(layers.LayerTypeICMPv6NeighborSolicitation); solicitationLayer != nil {
+		solicitation, _ := solicitationLayer.(*layers.ICMPv6NeighborSolicitation)
+		ipv6Layer := packet.Layer(layers.LayerTypeIPv6)
+		if ipv6Layer == nil {
+
+			return nil
+		}
+
+		ipv6, _ := ipv6Layer.(*layers.IPv6)
+		return sightingsOf(linkAddressOption(solicitation.Options, layers.ICMPv6OptSourceAddress), ipv6.SrcIP)
+	}
+
+	if advertisementLayer := packet.Layer(layers.LayerTypeICMPv6NeighborAdvertisement); advertisementLayer != nil {
+		advertisement, _ := advertisementLayer.(*layers.ICMPv6NeighborAdvertisement)
+
+		return sightingsOf(linkAddressOption(advertisement.Options, layers.ICMPv6OptTargetAddress), advertisement.TargetAddress)
+	}
+
+	var srcIp net.IP
+	if ipv4Layer := packet.Layer(layers.LayerTypeIPv4); ipv4Layer != nil {
+		srcIp = ipv4Layer.(*layers.IPv4).SrcIP
+	} else if ipv6Layer := packet.Layer(layers.LayerTypeIPv6); ipv6Layer != nil {
+		srcIp = ipv6Layer.(*layers.IPv6).SrcIP
+	}
+
+	if srcIp == nil || !(srcIp.IsPrivate() || srcIp.IsLinkLocalUnicast()) {
+
+		return nil
+	}
+
+	return sightingsOf(ethernet.SrcMAC, srcIp)
+}
+
+func linkAddressOption(options layers.ICMPv6Options, optionType layers.ICMPv6Opt) net.HardwareAddr {
+	for _, option := range options {
+		if option.Type == optionType {
+			return net.HardwareAddr(option.Data)
+		}
+	}
+
+	return nil
+}
+
+func sightingsOf(mac net.HardwareAddr, ip net.IP) []*model.Sighting {
+	if len(mac) != 6 || mac[0]&0x01 != 0 || isZero(mac) {
+
+		return nil
+	}
+
+	if ip == nil || ip.IsUnspecified() || ip.IsMulticast() || ip.IsLoopback() {
+
+		return nil
+	}
+
+	return []*model.Sighting{
+		{
+			Mac: mac.String(),
+			Ip:  ip.String(),
+		},
+	}
+}
+
+func isZero(mac net.HardwareAddr) bool {
+	for _, aByte := range mac {
+		if aByte != 0 {
+			return false
+		}
+	}
+
+	return true
+}
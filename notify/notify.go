@@ -0,0 +1,245 @@
+package notify
+
+import (
+	logFacility "auditor/logger"
+	"auditor/metrics"
+	"auditor/model"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dispatchInterval = 5 * time.Second
+	dispatchBatch    = 100
+	maxAttempts      = 10
+	minBackoff       = 10 * time.Second
+	maxBackoff       = time.Hour
+)
+
+type NotifyConfiguration struct {
+	SinksFile *string
+}
+
+// Notifier queues every fired alert in the outbox once per sink and delivers
+// them in the background, retrying with exponential backoff.
+type Notifier struct {
+	logger *logFacility.Logger
+	model  *model.Model
+	client *http.Client
+	sinks  map[string]*sink
+
+	wake        chan bool
+	ticker      *time.Ticker
+	tickersDone chan bool
+}
+
+func New(logger *logFacility.Logger, model *model.Model, notifyConf *NotifyConfiguration) (*Notifier, error) {
+	toReturn := &Notifier{
+		logger:      logger,
+		model:       model,
+		client:      &http.Client{Timeout: sendTimeout},
+		sinks:       make(map[string]*sink),
+		wake:        make(chan bool, 1),
+		ticker:      time.NewTicker(dispatchInterval),
+		tickersDone: make(chan bool),
+	}
+
+	if notifyConf.SinksFile == nil || strings.EqualFold(*notifyConf.SinksFile, "") {
+		logger.Log.Info("No notification sinks configured")
+		return toReturn, nil
+	}
+
+	sinks, err := readSinks(*notifyConf.SinksFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, aSink := range sinks {
+		toReturn.sinks[aSink.name] = aSink
+	}
+	logger.Log.Infof("Loaded %d notification sinks", len(sinks))
+
+	return toReturn, nil
+}
+
+// Notify queues the alert for every sink accepting its severity.
+func (n *Notifier) Notify(alert *model.Alert) {
+	queued := false
+	for _, aSink := range n.sinks {
+		if alert.Severity < aSink.minSeverity {
+			continue
+		}
+
+		err := n.model.EnqueueNotification(&model.Notification{
+			Sink:        aSink.name,
+			Alert:       alert,
+			NextAttempt: time.Now(),
+		})
+		if err != nil {
+			n.logger.Log.Warn(err)
+			continue
+		}
+		queued = true
+	}
+
+	if queued {
+		select {
+		case n.wake <- true:
+		default:
+		}
+	}
+}
+
+func (n *Notifier) Run() {
+	n.dispatch()
+	for {
+		select {
+		case <-n.tickersDone:
+			return
+		case <-n.ticker.C:
+			n.dispatch()
+		case <-n.wake:
+			n.dispatch()
+		}
+	}
+}
+
+func (n *Notifier) Dispose() {
+	n.ticker.Stop()
+	close(n.tickersDone)
+}
+
+func (n *Notifier) dispatch() {
+	// a sink that is rate limited or failing does not hold the others back
+	limits := make(map[string]int, len(n.sinks))
+	for name, aSink := range n.sinks {
+		limits[name] = aSink.limiter.available()
+		if limits[name] > dispatchBatch {
+			limits[name] = dispatchBatch
+		}
+	}
+
+	bySink, err := n.model.PendingNotifications(time.Now(), limits, dispatchBatch)
+	if err != nil {
+		n.logger.Log.Warn(err)
+		return
+	}
+
+	var wait sync.WaitGroup
+	for name, sinkNotifications := range bySink {
+		aSink, ok := n.sinks[name]
+		if !ok {
+			n.logger.Log.Warnf("Dropping %d notifications for sink %s, it is not configured anymore", len(sinkNotifications), name)
+			for _, aNotification := range sinkNotifications {
+				n.drop(aNotification)
+			}
+			continue
+		}
+
+		wait.Add(1)
+		go func(aSink *sink, sinkNotifications []*model.Notification) {
+			defer wait.Done()
+			for _, aNotification := range sinkNotifications {
+				if !aSink.limiter.allow() || !n.deliver(aSink, aNotification) {
+					return
+				}
+			}
+		}(aSink, sinkNotifications)
+	}
+
+	wait.Wait()
+}
+
+// deliver sends the notification, it returns false when the sink failed.
+func (n *Notifier) deliver(aSink *sink, notification *model.Notification) bool {
+	err := aSink.sender.send(n.client, notification.Alert)
+	if err == nil {
+		metrics.Notifications.WithLabelValues(aSink.name, "sent").Inc()
+		if err := n.model.DeleteNotification(notification); err != nil {
+			n.logger.Log.Warn(err)
+		}
+		return true
+	}
+
+	metrics.Notifications.WithLabelValues(aSink.name, "failed").Inc()
+	notification.Attempts++
+	notification.LastError = err.Error()
+	if notification.Attempts >= maxAttempts {
+		n.logger.Log.Warnf("Giving up notifying alert %s to %s after %d attempts: %s", notification.Alert.Id, aSink.name, notification.Attempts, err)
+		n.drop(notification)
+		return false
+	}
+
+	n.logger.Log.Infof("Notifying alert %s to %s failed, attempt %d: %s", notification.Alert.Id, aSink.name, notification.Attempts, err)
+	notification.NextAttempt = time.Now().Add(backoff(notification.Attempts))
+	if err := n.model.UpdateNotification(notification); err != nil {
+		n.logger.Log.Warn(err)
+	}
+	return false
+}
+
+func (n *Notifier) drop(notification *model.Notification) {
+	metrics.Notifications.WithLabelValues(notification.Sink, "dropped").Inc()
+	if err := n.model.DeleteNotification(notification); err != nil {
+		n.logger.Log.Warn(err)
+	}
+}
+
+func backoff(attempts int) time.Duration {
+	toReturn := minBackoff << (attempts - 1)
+	if toReturn <= 0 || toReturn > maxBackoff {
+		return maxBackoff
+	}
+
+	return toReturn
+}
+
+// limiter is a token bucket refilled with rate tokens every period.
+type limiter struct {
+	mutex    sync.Mutex
+	capacity float64
+	tokens   float64
+	perNano  float64
+	last     time.Time
+}
+
+func newLimiter(rate int, period time.Duration) *limiter {
+	return &limiter{
+		capacity: float64(rate),
+		tokens:   float64(rate),
+		perNano:  float64(rate) / float64(period),
+		last:     time.Now(),
+	}
+}
+
+func (l *limiter) allow() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.refill()
+	if l.tokens < 1 {
+		return false
+	}
+
+	l.tokens--
+	return true
+}
+
+// available returns how many sends are allowed right now.
+func (l *limiter) available() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.refill()
+	return int(l.tokens)
+}
+
+func (l *limiter) refill() {
+	now := time.Now()
+	l.tokens += float64(now.Sub(l.last)) * l.perNano
+	if l.tokens > l.capacity {
+		l.tokens = l.capacity
+	}
+	l.last = now
+}
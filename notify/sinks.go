@@ -0,0 +1,228 @@
+package notify
+
+import (
+	"auditor/model"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRatePerMinute = 30
+	sendTimeout          = 10 * time.Second
+	signatureHeader      = "X-Auditor-Signature"
+)
+
+// SinkDefinition is how sinks are written in the sinks file, a json array of
+// them. Type is one of webhook, slack, ntfy or gotify.
+type SinkDefinition struct {
+	Name          string         `json:"name"`
+	Type          string         `json:"type"`
+	Url           string         `json:"url"`
+	Secret        string         `json:"secret,omitempty"`
+	Token         string         `json:"token,omitempty"`
+	MinSeverity   model.Severity `json:"minSeverity,omitempty"`
+	RatePerMinute int            `json:"ratePerMinute,omitempty"`
+}
+
+type sender interface {
+	send(client *http.Client, alert *model.Alert) error
+}
+
+type sink struct {
+	name        string
+	minSeverity model.Severity
+	limiter     *limiter
+	sender      sender
+}
+
+func readSinks(file string) ([]*sink, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	definitions := make([]*SinkDefinition, 0)
+	if err := json.Unmarshal(content, &definitions); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	toReturn := make([]*sink, 0, len(definitions))
+	names := make(map[string]bool, len(definitions))
+	for _, aDefinition := range definitions {
+		if names[aDefinition.Name] {
+			return nil, fmt.Errorf("%s: sink %s is defined twice", file, aDefinition.Name)
+		}
+		names[aDefinition.Name] = true
+
+		aSink, err := sinkFrom(aDefinition)
+		if err != nil {
+			return nil, fmt.Errorf("%s: sink %s: %w", file, aDefinition.Name, err)
+		}
+
+		toReturn = append(toReturn, aSink)
+	}
+
+	return toReturn, nil
+}
+
+func sinkFrom(definition *SinkDefinition) (*sink, error) {
+	if strings.EqualFold(definition.Name, "") {
+		return nil, fmt.Errorf("name is mandatory")
+	}
+
+	if strings.EqualFold(definition.Url, "") {
+		return nil, fmt.Errorf("url is mandatory")
+	}
+
+	rate := definition.RatePerMinute
+	if rate <= 0 {
+		rate = defaultRatePerMinute
+	}
+
+	toReturn := &sink{
+		name:        definition.Name,
+		minSeverity: definition.MinSeverity,
+		limiter:     newLimiter(rate, time.Minute),
+	}
+
+	switch strings.ToLower(definition.Type) {
+	case "webhook":
+		toReturn.sender = &webhook{url: definition.Url, secret: []byte(definition.Secret)}
+	case "slack", "mattermost":
+		toReturn.sender = &slack{url: definition.Url}
+	case "ntfy":
+		toReturn.sender = &ntfy{url: definition.Url, token: definition.Token}
+	case "gotify":
+		toReturn.sender = &gotify{url: strings.TrimSuffix(definition.Url, "/") + "/message", token: definition.Token}
+	default:
+		return nil, fmt.Errorf("type %s does not exist, use one of webhook, slack, ntfy, gotify", definition.Type)
+	}
+
+	return toReturn, nil
+}
+
+// webhook posts the alert as json, signed with HMAC-SHA256 of the body when a
+// secret is set.
+type webhook struct {
+	url    string
+	secret []byte
+}
+
+func (w *webhook) send(client *http.Client, alert *model.Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	if len(w.secret) > 0 {
+		mac := hmac.New(sha256.New, w.secret)
+		mac.Write(body)
+		request.Header.Set(signatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	return do(client, request)
+}
+
+// slack posts incoming webhook payloads, which Mattermost accepts too.
+type slack struct {
+	url string
+}
+
+func (s *slack) send(client *http.Client, alert *model.Alert) error {
+	body, err := json.Marshal(map[string]string{
+		"text": fmt.Sprintf("*[%s] %s*\n%s", strings.ToUpper(alert.Severity.String()), alert.Rule, alert.Description),
+	})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	return do(client, request)
+}
+
+// ntfy publishes to the topic url, mapping the severity to the ntfy priority.
+type ntfy struct {
+	url   string
+	token string
+}
+
+func (n *ntfy) send(client *http.Client, alert *model.Alert) error {
+	request, err := http.NewRequest(http.MethodPost, n.url, strings.NewReader(alert.Description))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Title", alert.Rule)
+	request.Header.Set("Priority", strconv.Itoa(priority(alert.Severity)))
+	request.Header.Set("Tags", alert.Severity.String())
+	if !strings.EqualFold(n.token, "") {
+		request.Header.Set("Authorization", "Bearer "+n.token)
+	}
+
+	return do(client, request)
+}
+
+type gotify struct {
+	url   string
+	token string
+}
+
+func (g *gotify) send(client *http.Client, alert *model.Alert) error {
+	body, err := json.Marshal(map[string]any{
+		"title":    alert.Rule,
+		"message":  alert.Description,
+		"priority": 2 * priority(alert.Severity),
+	})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, g.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Gotify-Key", g.token)
+
+	return do(client, request)
+}
+
+// priority maps severities to 1-5, the ntfy scale.
+func priority(severity model.Severity) int {
+	return int(severity) + 1
+}
+
+func do(client *http.Client, request *http.Request) error {
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("%s answered %s: %s", request.URL.Host, response.Status, strings.TrimSpace(string(message)))
+	}
+
+	return nil
+}
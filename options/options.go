@@ -1,12 +1,22 @@
 package options
 
 import (
+	"auditor/alerts"
+	"auditor/analysis"
+	"auditor/api"
+	"auditor/dhcp"
+	"auditor/exports"
+	"auditor/healthiness"
+	"auditor/intel"
 	logFacility "auditor/logger"
 	"auditor/meta"
 	"auditor/model"
+	"auditor/notify"
+	"auditor/serving"
 	"errors"
 	"flag"
 	"fmt"
+	"net"
 	"os"
 	"strconv"
 	"strings"
@@ -41,13 +51,117 @@ var (
 	logEnvironmentEnv, logEnvironmentEnvSet = os.LookupEnv("LOG_ENVIRONMENT")
 	logEnvironment                          = flag.String("log-environment", "", "Log environment")
 
+	apiTokensFileEnv, apiTokensFileEnvSet = os.LookupEnv("API_TOKENS_FILE")
+	apiTokensFile                         = flag.String("api-tokens-file", "", "File with a \"<token> <role> [name]\" bearer token per line. Roles are read-only or admin")
+
+	apiBasicFileEnv, apiBasicFileEnvSet = os.LookupEnv("API_BASIC_FILE")
+	apiBasicFile                        = flag.String("api-basic-file", "", "File with a \"<user>:<bcrypt hash>:<role>\" basic auth user per line")
+
+	apiClientCertificatesFileEnv, apiClientCertificatesFileEnvSet = os.LookupEnv("API_CLIENT_CERTIFICATES_FILE")
+	apiClientCertificatesFile                                     = flag.String("api-client-certificates-file", "", "File with a \"<common name> <role>\" client certificate per line")
+
+	apiListenAddrEnv, apiListenAddrEnvSet = os.LookupEnv("API_LISTEN_ADDR")
+	apiListenAddr                         = flag.String("api-listen-addr", ":3000", "Api listen address, either host:port or unix:///path/to/socket")
+
+	apiTlsCertEnv, apiTlsCertEnvSet = os.LookupEnv("API_TLS_CERT")
+	apiTlsCert                      = flag.String("api-tls-cert", "", "Api tls certificate file, reloaded when it changes. Plain http when empty")
+
+	apiTlsKeyEnv, apiTlsKeyEnvSet = os.LookupEnv("API_TLS_KEY")
+	apiTlsKey                     = flag.String("api-tls-key", "", "Api tls key file")
+
+	apiTlsSelfSignedEnv, apiTlsSelfSignedEnvSet = os.LookupEnv("API_TLS_SELF_SIGNED")
+	apiTlsSelfSigned                            = flag.Bool("api-tls-self-signed", false, "Generate a self-signed api certificate and key when their files are missing")
+
+	apiTlsClientCaEnv, apiTlsClientCaEnvSet = os.LookupEnv("API_TLS_CLIENT_CA")
+	apiTlsClientCa                          = flag.String("api-tls-client-ca", "", "CA bundle used to verify api client certificates")
+
+	healthListenAddrEnv, healthListenAddrEnvSet = os.LookupEnv("HEALTH_LISTEN_ADDR")
+	healthListenAddr                            = flag.String("health-listen-addr", ":8080", "Healthiness listen address, either host:port or unix:///path/to/socket")
+
+	healthTlsCertEnv, healthTlsCertEnvSet = os.LookupEnv("HEALTH_TLS_CERT")
+	healthTlsCert                         = flag.String("health-tls-cert", "", "Healthiness tls certificate file, reloaded when it changes. Plain http when empty")
+
+	healthTlsKeyEnv, healthTlsKeyEnvSet = os.LookupEnv("HEALTH_TLS_KEY")
+	healthTlsKey                        = flag.String("health-tls-key", "", "Healthiness tls key file")
+
+	healthTlsSelfSignedEnv, healthTlsSelfSignedEnvSet = os.LookupEnv("HEALTH_TLS_SELF_SIGNED")
+	healthTlsSelfSigned                               = flag.Bool("health-tls-self-signed", false, "Generate a self-signed healthiness certificate and key when their files are missing")
+
+	healthMaxSilenceEnv, healthMaxSilenceEnvSet = os.LookupEnv("HEALTH_MAX_SILENCE")
+	healthMaxSilence                            = flag.Duration("health-max-silence", 10*time.Minute, "Readiness fails when no traffic is received for this long")
+
+	dhcpLeasesFileEnv, dhcpLeasesFileEnvSet = os.LookupEnv("DHCP_LEASES_FILE")
+	dhcpLeasesFile                          = flag.String("dhcp-leases-file", "", "dnsmasq or ISC dhcpd lease file used to name devices. Disabled when empty")
+
+	dhcpLeasesIntervalEnv, dhcpLeasesIntervalEnvSet = os.LookupEnv("DHCP_LEASES_INTERVAL")
+	dhcpLeasesInterval                              = flag.Duration("dhcp-leases-interval", time.Minute, "How often the lease file is checked for changes")
+
+	localCidrsEnv, localCidrsEnvSet = os.LookupEnv("LOCAL_CIDRS")
+	localCidrs                      = flag.String("local-cidrs", "", "Comma separated networks treated as local on top of RFC1918, ULA and link-local ones. Local addresses are only reverse resolved, never sent to external providers nor exported")
+
+	localSuffixesEnv, localSuffixesEnvSet = os.LookupEnv("LOCAL_SUFFIXES")
+	localSuffixes                         = flag.String("local-suffixes", "lan,local,home.arpa,internal", "Comma separated domains whose reverse resolved addresses are treated as local, they are never exported")
+
+	torExitsFilesEnv, torExitsFilesEnvSet = os.LookupEnv("TOR_EXITS_FILES")
+	torExitsFiles                         = flag.String("tor-exits-files", "", "Comma separated Tor exit lists, bulk or exit-addresses format")
+
+	vpnRangesFilesEnv, vpnRangesFilesEnvSet = os.LookupEnv("VPN_RANGES_FILES")
+	vpnRangesFiles                          = flag.String("vpn-ranges-files", "", "Comma separated files with a VPN range per line, named after their provider")
+
+	hostingRangesFilesEnv, hostingRangesFilesEnvSet = os.LookupEnv("HOSTING_RANGES_FILES")
+	hostingRangesFiles                              = flag.String("hosting-ranges-files", "", "Comma separated hosting ranges, AWS, Google Cloud and Azure json files as published or files with a range per line named after their provider")
+
+	categoriesDirEnv, categoriesDirEnvSet = os.LookupEnv("CATEGORIES_DIR")
+	categoriesDir                         = flag.String("categories-dir", "", "UT1 blacklists like folder, a subfolder per category with a domains file, used to categorize hostnames. No categorization when empty")
+
+	trackerBlocklistsEnv, trackerBlocklistsEnvSet = os.LookupEnv("TRACKER_BLOCKLISTS")
+	trackerBlocklists                             = flag.String("tracker-blocklists", "", "Comma separated ad and tracker lists, hosts files like StevenBlack ones or Adblock lists like EasyPrivacy")
+
+	learningPeriodEnv, learningPeriodEnvSet = os.LookupEnv("LEARNING_PERIOD")
+	learningPeriod                          = flag.Duration("learning-period", model.DefaultLearningPeriod, "How long after a device is first seen its new destinations are not reported")
+
+	alertRulesFileEnv, alertRulesFileEnvSet = os.LookupEnv("ALERT_RULES_FILE")
+	alertRulesFile                          = flag.String("alert-rules-file", "", "Json file with the alert rules evaluated against every action. No alerts when empty")
+
+	beaconsIntervalEnv, beaconsIntervalEnvSet = os.LookupEnv("BEACONS_INTERVAL")
+	beaconsInterval                           = flag.Duration("beacons-interval", 15*time.Minute, "How often connections are analyzed for beaconing")
+
+	beaconsBurstWindowEnv, beaconsBurstWindowEnvSet = os.LookupEnv("BEACONS_BURST_WINDOW")
+	beaconsBurstWindow                              = flag.Duration("beacons-burst-window", analysis.DefaultBurstWindow, "Contacts of a destination closer than this are counted as a single connection when looking for beaconing")
+
+	beaconsMinScoreEnv, beaconsMinScoreEnvSet = os.LookupEnv("BEACONS_MIN_SCORE")
+	beaconsMinScore                           = flag.Float64("beacons-min-score", 0.8, "Beacon score, from 0 to 1, from which periodic connections fire an alert")
+
+	notifySinksFileEnv, notifySinksFileEnvSet = os.LookupEnv("NOTIFY_SINKS_FILE")
+	notifySinksFile                           = flag.String("notify-sinks-file", "", "Json file with the webhook, slack, ntfy and gotify sinks alerts are sent to. No notifications when empty")
+
+	threatIntelFeedsFileEnv, threatIntelFeedsFileEnvSet = os.LookupEnv("THREAT_INTEL_FEEDS_FILE")
+	threatIntelFeedsFile                                = flag.String("threat-intel-feeds-file", "", "Json file with the threat intelligence feeds, urls or paths in text, csv, stix, abusech or misp format. No feeds when empty")
+
+	threatIntelRefreshEnv, threatIntelRefreshEnvSet = os.LookupEnv("THREAT_INTEL_REFRESH")
+	threatIntelRefresh                              = flag.Duration("threat-intel-refresh", 6*time.Hour, "How often threat intelligence feeds are reloaded")
+
+	exportsFileEnv, exportsFileEnvSet = os.LookupEnv("EXPORTS_FILE")
+	exportsFile                       = flag.String("exports-file", "", "Json file with the hosts, dnsmasq, nftables and unbound blocklists written to disk on a schedule. No exports when empty")
+
+	exportsIntervalEnv, exportsIntervalEnvSet = os.LookupEnv("EXPORTS_INTERVAL")
+	exportsInterval                           = flag.Duration("exports-interval", time.Hour, "How often blocklists are written to disk")
+
 	autocomplete = flag.Bool("zsh-autocomplete", false, "Print zsh autocomplete")
 )
 
 type OptionsBase struct {
-	Model  *model.ModelConfigurations
-	Meta   *meta.MetaConfiguration
-	Logger *logFacility.Logger
+	Model    *model.ModelConfigurations
+	Meta     *meta.MetaConfiguration
+	Api      *api.ApiConfiguration
+	Health   *healthiness.HealthinessConfiguration
+	Dhcp     *dhcp.DhcpConfiguration
+	Alerts   *alerts.AlertsConfiguration
+	Notify   *notify.NotifyConfiguration
+	Analysis *analysis.AnalysisConfiguration
+	Intel    *intel.IntelConfiguration
+	Exports  *exports.ExportsConfiguration
+	Logger   *logFacility.Logger
 }
 
 func Parse() (*OptionsBase, error) {
@@ -121,12 +235,214 @@ func Parse() (*OptionsBase, error) {
 		applicationName = &applicatioNameEnv
 	}
 
+	if apiTokensFileEnvSet {
+		apiTokensFile = &apiTokensFileEnv
+	}
+
+	if apiBasicFileEnvSet {
+		apiBasicFile = &apiBasicFileEnv
+	}
+
+	if apiClientCertificatesFileEnvSet {
+		apiClientCertificatesFile = &apiClientCertificatesFileEnv
+	}
+
+	if apiListenAddrEnvSet {
+		apiListenAddr = &apiListenAddrEnv
+	}
+
+	if apiTlsCertEnvSet {
+		apiTlsCert = &apiTlsCertEnv
+	}
+
+	if apiTlsKeyEnvSet {
+		apiTlsKey = &apiTlsKeyEnv
+	}
+
+	if apiTlsSelfSignedEnvSet {
+		apiTlsSelfSignedFromEnv, err := strconv.ParseBool(apiTlsSelfSignedEnv)
+		if err != nil {
+			return nil, err
+		}
+
+		*apiTlsSelfSigned = apiTlsSelfSignedFromEnv
+	}
+
+	if apiTlsClientCaEnvSet {
+		apiTlsClientCa = &apiTlsClientCaEnv
+	}
+
+	if healthListenAddrEnvSet {
+		healthListenAddr = &healthListenAddrEnv
+	}
+
+	if healthTlsCertEnvSet {
+		healthTlsCert = &healthTlsCertEnv
+	}
+
+	if healthTlsKeyEnvSet {
+		healthTlsKey = &healthTlsKeyEnv
+	}
+
+	if healthTlsSelfSignedEnvSet {
+		healthTlsSelfSignedFromEnv, err := strconv.ParseBool(healthTlsSelfSignedEnv)
+		if err != nil {
+			return nil, err
+		}
+
+		*healthTlsSelfSigned = healthTlsSelfSignedFromEnv
+	}
+
+	if healthMaxSilenceEnvSet {
+		healthMaxSilenceFromEnv, err := time.ParseDuration(healthMaxSilenceEnv)
+		if err != nil {
+			return nil, err
+		}
+
+		*healthMaxSilence = healthMaxSilenceFromEnv
+	}
+
+	if dhcpLeasesFileEnvSet {
+		dhcpLeasesFile = &dhcpLeasesFileEnv
+	}
+
+	if dhcpLeasesIntervalEnvSet {
+		dhcpLeasesIntervalFromEnv, err := time.ParseDuration(dhcpLeasesIntervalEnv)
+		if err != nil {
+			return nil, err
+		}
+
+		*dhcpLeasesInterval = dhcpLeasesIntervalFromEnv
+	}
+
+	if localCidrsEnvSet {
+		localCidrs = &localCidrsEnv
+	}
+
+	var localNetworks []*net.IPNet
+	for _, cidr := range strings.Split(*localCidrs, ",") {
+		if strings.TrimSpace(cidr) == "" {
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, err
+		}
+		localNetworks = append(localNetworks, network)
+	}
+
+	if localSuffixesEnvSet {
+		localSuffixes = &localSuffixesEnv
+	}
+
+	if torExitsFilesEnvSet {
+		torExitsFiles = &torExitsFilesEnv
+	}
+
+	if vpnRangesFilesEnvSet {
+		vpnRangesFiles = &vpnRangesFilesEnv
+	}
+
+	if hostingRangesFilesEnvSet {
+		hostingRangesFiles = &hostingRangesFilesEnv
+	}
+
+	if categoriesDirEnvSet {
+		categoriesDir = &categoriesDirEnv
+	}
+
+	if trackerBlocklistsEnvSet {
+		trackerBlocklists = &trackerBlocklistsEnv
+	}
+
+	if learningPeriodEnvSet {
+		learningPeriodFromEnv, err := time.ParseDuration(learningPeriodEnv)
+		if err != nil {
+			return nil, err
+		}
+
+		*learningPeriod = learningPeriodFromEnv
+	}
+
+	if alertRulesFileEnvSet {
+		alertRulesFile = &alertRulesFileEnv
+	}
+
+	if beaconsIntervalEnvSet {
+		beaconsIntervalFromEnv, err := time.ParseDuration(beaconsIntervalEnv)
+		if err != nil {
+			return nil, err
+		}
+
+		*beaconsInterval = beaconsIntervalFromEnv
+	}
+
+	if beaconsBurstWindowEnvSet {
+		beaconsBurstWindowFromEnv, err := time.ParseDuration(beaconsBurstWindowEnv)
+		if err != nil {
+			return nil, err
+		}
+
+		*beaconsBurstWindow = beaconsBurstWindowFromEnv
+	}
+
+	if beaconsMinScoreEnvSet {
+		beaconsMinScoreFromEnv, err := strconv.ParseFloat(beaconsMinScoreEnv, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		*beaconsMinScore = beaconsMinScoreFromEnv
+	}
+
+	if notifySinksFileEnvSet {
+		notifySinksFile = &notifySinksFileEnv
+	}
+
+	if threatIntelFeedsFileEnvSet {
+		threatIntelFeedsFile = &threatIntelFeedsFileEnv
+	}
+
+	if threatIntelRefreshEnvSet {
+		threatIntelRefreshFromEnv, err := time.ParseDuration(threatIntelRefreshEnv)
+		if err != nil {
+			return nil, err
+		}
+
+		*threatIntelRefresh = threatIntelRefreshFromEnv
+	}
+
+	if exportsFileEnvSet {
+		exportsFile = &exportsFileEnv
+	}
+
+	if exportsIntervalEnvSet {
+		exportsIntervalFromEnv, err := time.ParseDuration(exportsIntervalEnv)
+		if err != nil {
+			return nil, err
+		}
+
+		*exportsInterval = exportsIntervalFromEnv
+	}
+
 	metaConf := &meta.MetaConfiguration{
 		ShodanApiKey:  shodanApiKey,
 		CacheSize:     cacheSize,
 		CacheEviction: cacheEviction,
 
 		Dns: dns,
+
+		LocalCidrs:    localNetworks,
+		LocalSuffixes: strings.Split(*localSuffixes, ","),
+
+		TorExitsFiles:      strings.Split(*torExitsFiles, ","),
+		VpnRangesFiles:     strings.Split(*vpnRangesFiles, ","),
+		HostingRangesFiles: strings.Split(*hostingRangesFiles, ","),
+
+		CategoriesDir: categoriesDir,
+
+		TrackerBlocklists: strings.Split(*trackerBlocklists, ","),
 	}
 
 	if *autocomplete {
@@ -138,8 +454,53 @@ func Parse() (*OptionsBase, error) {
 			PathWhereStoreDabaseFile: pathWhereStoreDabaseFile,
 			ApplicationName:          applicationName,
 			ModelMergersTime:         defaultModelMergersTime,
+			LearningPeriod:           learningPeriod,
 		},
 		Meta: metaConf,
+		Api: &api.ApiConfiguration{
+			TokensFile:             apiTokensFile,
+			BasicFile:              apiBasicFile,
+			ClientCertificatesFile: apiClientCertificatesFile,
+			Serving: &serving.ServingConfiguration{
+				ListenAddr:   apiListenAddr,
+				CertFile:     apiTlsCert,
+				KeyFile:      apiTlsKey,
+				SelfSigned:   apiTlsSelfSigned,
+				ClientCaFile: apiTlsClientCa,
+			},
+		},
+		Health: &healthiness.HealthinessConfiguration{
+			Serving: &serving.ServingConfiguration{
+				ListenAddr: healthListenAddr,
+				CertFile:   healthTlsCert,
+				KeyFile:    healthTlsKey,
+				SelfSigned: healthTlsSelfSigned,
+			},
+			MaxSilence: healthMaxSilence,
+		},
+		Dhcp: &dhcp.DhcpConfiguration{
+			LeasesFile:     dhcpLeasesFile,
+			LeasesInterval: dhcpLeasesInterval,
+		},
+		Alerts: &alerts.AlertsConfiguration{
+			RulesFile: alertRulesFile,
+		},
+		Notify: &notify.NotifyConfiguration{
+			SinksFile: notifySinksFile,
+		},
+		Analysis: &analysis.AnalysisConfiguration{
+			BeaconsInterval:    beaconsInterval,
+			BeaconsBurstWindow: beaconsBurstWindow,
+			BeaconsMinScore:    beaconsMinScore,
+		},
+		Intel: &intel.IntelConfiguration{
+			FeedsFile:       threatIntelFeedsFile,
+			RefreshInterval: threatIntelRefresh,
+		},
+		Exports: &exports.ExportsConfiguration{
+			ExportsFile: exportsFile,
+			Interval:    exportsInterval,
+		},
 		Logger: &logFacility.Logger{
 			Log: sugar,
 		},
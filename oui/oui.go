@@ -0,0 +1,18 @@
+package oui
+
+import (
+	"net"
+
+	"github.com/google/gopacket/macs"
+)
+
+// Vendor returns the organization the IEEE assigned the address prefix to.
+// Locally administered addresses, like the randomized ones phones use for
+// privacy, have no vendor and return an empty string.
+func Vendor(mac net.HardwareAddr) string {
+	if len(mac) < 3 || mac[0]&0x02 != 0 {
+		return ""
+	}
+
+	return macs.ValidMACPrefixMap[[3]byte{mac[0], mac[1], mac[2]}]
+}
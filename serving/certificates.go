@@ -0,0 +1,202 @@
+package serving
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	logFacility "auditor/logger"
+)
+
+const reloadCheckInterval = 30 * time.Second
+
+// certificateReloader serves the certificate from disk and reloads it when its
+// files change, so that renewals do not need a restart.
+type certificateReloader struct {
+	logger   *logFacility.Logger
+	certFile string
+	keyFile  string
+
+	mutex       *sync.RWMutex
+	certificate *tls.Certificate
+	modTime     time.Time
+	lastCheck   time.Time
+}
+
+func newCertificateReloader(logger *logFacility.Logger, certFile string, keyFile string) (*certificateReloader, error) {
+	toReturn := &certificateReloader{
+		logger:   logger,
+		certFile: certFile,
+		keyFile:  keyFile,
+
+		mutex: &sync.RWMutex{},
+	}
+
+	if err := toReturn.reload(); err != nil {
+		return nil, err
+	}
+
+	return toReturn, nil
+}
+
+func (r *certificateReloader) filesModTime() (time.Time, error) {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if keyInfo.ModTime().After(certInfo.ModTime()) {
+		return keyInfo.ModTime(), nil
+	}
+
+	return certInfo.ModTime(), nil
+}
+
+func (r *certificateReloader) reload() error {
+	modTime, err := r.filesModTime()
+	if err != nil {
+		return err
+	}
+
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.certificate = &certificate
+	r.modTime = modTime
+	r.lastCheck = time.Now()
+
+	return nil
+}
+
+func (r *certificateReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mutex.RLock()
+	certificate := r.certificate
+	needsCheck := time.Since(r.lastCheck) > reloadCheckInterval
+	modTime := r.modTime
+	r.mutex.RUnlock()
+
+	if !needsCheck {
+		return certificate, nil
+	}
+
+	r.mutex.Lock()
+	r.lastCheck = time.Now()
+	r.mutex.Unlock()
+
+	currentModTime, err := r.filesModTime()
+	if err != nil || !currentModTime.After(modTime) {
+		return certificate, nil
+	}
+
+	if err := r.reload(); err != nil {
+		r.logger.Log.Warnf("Keeping the previous certificate, reloading %s failed: %v", r.certFile, err)
+		return certificate, nil
+	}
+
+	r.logger.Log.Infof("Reloaded certificate %s", r.certFile)
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.certificate, nil
+}
+
+func generateSelfSignedIfMissing(certFile string, keyFile string) (bool, error) {
+	_, certErr := os.Stat(certFile)
+	_, keyErr := os.Stat(keyFile)
+	if certErr == nil && keyErr == nil {
+		return false, nil
+	}
+
+	if !errors.Is(certErr, os.ErrNotExist) && certErr != nil {
+		return false, certErr
+	}
+
+	if !errors.Is(keyErr, os.ErrNotExist) && keyErr != nil {
+		return false, keyErr
+	}
+
+	if certErr == nil || keyErr == nil {
+		return false, fmt.Errorf("only one of %s and %s exists, refusing to overwrite it", certFile, keyFile)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return false, err
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return false, err
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "auditor"
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			CommonName:   hostname,
+			Organization: []string{"auditor"},
+		},
+		DNSNames:              []string{hostname, "localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return false, err
+	}
+
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	if err := writePem(keyFile, "EC PRIVATE KEY", keyBytes, 0600); err != nil {
+		return false, err
+	}
+
+	if err := writePem(certFile, "CERTIFICATE", certificate, 0644); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func writePem(file string, blockType string, bytes []byte, mode os.FileMode) error {
+	writer, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	if err := pem.Encode(writer, &pem.Block{Type: blockType, Bytes: bytes}); err != nil {
+		writer.Close()
+		return err
+	}
+
+	return writer.Close()
+}
@@ -0,0 +1,161 @@
+package serving
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	logFacility "auditor/logger"
+)
+
+const unixScheme = "unix"
+
+type ServingConfiguration struct {
+	ListenAddr *string
+
+	CertFile     *string
+	KeyFile      *string
+	SelfSigned   *bool
+	ClientCaFile *string
+}
+
+func (c *ServingConfiguration) isTls() bool {
+	return isSet(c.CertFile) || isSet(c.KeyFile)
+}
+
+// VerifiesClients tells whether clients can present certificates signed by
+// the configured authority.
+func (c *ServingConfiguration) VerifiesClients() bool {
+	return c.isTls() && isSet(c.ClientCaFile)
+}
+
+func (c *ServingConfiguration) validate() error {
+	if c.isTls() && (!isSet(c.CertFile) || !isSet(c.KeyFile)) {
+		return errors.New("tls needs both certificate and key files")
+	}
+
+	if c.SelfSigned != nil && *c.SelfSigned && !c.isTls() {
+		return errors.New("a self-signed certificate needs certificate and key paths to be generated at")
+	}
+
+	if isSet(c.ClientCaFile) && !c.isTls() {
+		return errors.New("verifying client certificates needs tls")
+	}
+
+	return nil
+}
+
+func isSet(value *string) bool {
+	return value != nil && !strings.EqualFold(*value, "")
+}
+
+// Serve listens on a tcp address, like :3000, or on a unix socket, like
+// unix:///run/auditor.sock, and serves plain http or tls when a certificate is
+// configured.
+func Serve(logger *logFacility.Logger, name string, conf *ServingConfiguration, handler http.Handler) error {
+	if err := conf.validate(); err != nil {
+		return fmt.Errorf("%s server: %w", name, err)
+	}
+
+	listener, err := listen(*conf.ListenAddr)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	if !conf.isTls() {
+		logger.Log.Infof("Starting %s server on %s", name, *conf.ListenAddr)
+		return server.Serve(listener)
+	}
+
+	tlsConfig, err := tlsConfigFrom(logger, conf)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	server.TLSConfig = tlsConfig
+
+	logger.Log.Infof("Starting %s server with tls on %s", name, *conf.ListenAddr)
+	return server.Serve(tls.NewListener(listener, tlsConfig))
+}
+
+func listen(listenAddr string) (net.Listener, error) {
+	if !strings.HasPrefix(listenAddr, unixScheme+"://") {
+		return net.Listen("tcp", listenAddr)
+	}
+
+	socketUrl, err := url.Parse(listenAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	socketPath := socketUrl.Host + socketUrl.Path
+	if err := os.Remove(socketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	listener, err := net.Listen(unixScheme, socketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(socketPath, 0660); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+func tlsConfigFrom(logger *logFacility.Logger, conf *ServingConfiguration) (*tls.Config, error) {
+	if conf.SelfSigned != nil && *conf.SelfSigned {
+		generated, err := generateSelfSignedIfMissing(*conf.CertFile, *conf.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		if generated {
+			logger.Log.Infof("Generated self-signed certificate %s", *conf.CertFile)
+		}
+	}
+
+	reloader, err := newCertificateReloader(logger, *conf.CertFile, *conf.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	toReturn := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.getCertificate,
+	}
+
+	if isSet(conf.ClientCaFile) {
+		caBytes, err := os.ReadFile(*conf.ClientCaFile)
+		if err != nil {
+			return nil, err
+		}
+
+		clientCas := x509.NewCertPool()
+		if !clientCas.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("no certificates found in %s", *conf.ClientCaFile)
+		}
+
+		// certificates are optional so that clients can still authenticate
+		// with tokens or passwords
+		toReturn.ClientCAs = clientCas
+		toReturn.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return toReturn, nil
+}
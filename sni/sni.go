@@ -1,15 +1,18 @@
 package sni
 
 import (
+	"auditor/clienthello"
+	"auditor/dhcp"
+	"auditor/healthiness"
+	"auditor/metrics"
 	"auditor/model"
+	"auditor/neighbors"
 	"fmt"
-	"strconv"
 	"sync"
 
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
 	"github.com/google/gopacket/pcap"
-	"github.com/yarochewsky/tlsx"
 
 	logFacility "auditor/logger"
 )
@@ -23,13 +26,17 @@ type Handler struct {
 	logger      *logFacility.Logger
 	pcapHandler *pcap.Handle
 
-	C chan *model.Action
+	C         chan *model.Action
+	Sightings chan *model.Sighting
+	Heartbeat *healthiness.Heartbeat
 }
 
 func New(logger *logFacility.Logger, pcapConfs *PcapConfiguration) (*Handler, error) {
 	toReturn := &Handler{
-		logger: logger,
-		C:      make(chan *model.Action),
+		logger:    logger,
+		C:         make(chan *model.Action),
+		Sightings: make(chan *model.Sighting),
+		Heartbeat: healthiness.NewHeartbeat("packet capture"),
 	}
 
 	handler, err := pcap.OpenLive(*pcapConfs.Interface, 65536, true, pcap.BlockForever)
@@ -59,7 +66,11 @@ func (h *Handler) Handle() {
 
 	var wg sync.WaitGroup
 
+	h.Heartbeat.Started()
+	defer h.Heartbeat.Stopped()
 	for packet := range source.Packets() {
+		h.Heartbeat.Beat()
+		metrics.PacketsSeen.Inc()
 		wg.Add(1)
 		go h.managePacket(packet, &wg)
 	}
@@ -69,6 +80,15 @@ func (h *Handler) Handle() {
 func (h *Handler) managePacket(packet gopacket.Packet, wg *sync.WaitGroup) {
 	defer wg.Done()
 
+	for _, aSighting := range neighbors.FromPacket(packet) {
+		h.Sightings <- aSighting
+	}
+
+	if aSighting := dhcp.FromPacket(packet); aSighting != nil {
+		h.Sightings <- aSighting
+		return
+	}
+
 	if tcpLayer := packet.Layer(layers.LayerTypeTCP); tcpLayer != nil {
 		// cast TCP layer
 		tcp, ok := tcpLayer.(*layers.TCP)
@@ -84,41 +104,28 @@ func (h *Handler) managePacket(packet gopacket.Packet, wg *sync.WaitGroup) {
 			// data packet
 			// process TLS client hello
 			h.logger.Log.Debug("Got data")
-			clientHello := tlsx.GetClientHello(packet)
-			if clientHello != nil {
-				srcPortUi64, err := strconv.ParseUint(packet.TransportLayer().TransportFlow().Src().String(), 10, 64)
-				if err != nil {
-					h.logger.Log.Errorf("src port not a number")
-					return
-				}
-
-				dstPortUi64, err := strconv.ParseUint(packet.TransportLayer().TransportFlow().Dst().String(), 10, 64)
-				if err != nil {
-					h.logger.Log.Errorf("dst port not a number")
-					return
-				}
-
-				srcAddr := packet.NetworkLayer().NetworkFlow().Src().String()
-				dstAddr := packet.NetworkLayer().NetworkFlow().Dst().String()
-				srcPort := uint16(srcPortUi64)
-				dstPort := uint16(dstPortUi64)
-				hostName := clientHello.SNI
-
-				source := fmt.Sprintf("%s:%d", srcAddr, srcPort)
-				destination := fmt.Sprintf("%s:%d", dstAddr, dstPort)
-
-				h.logger.Log.Infof("[ %s -> %s ] | %s", source, destination, hostName)
-
-				h.C <- &model.Action{
-					SrcAddr:  &srcAddr,
-					DstAddr:  &dstAddr,
-					SrcPort:  &srcPort,
-					DstPort:  &dstPort,
-					Hostname: &hostName,
-				}
-			} else {
-
-				h.logger.Log.Debug("Not clientHello packet")
+			clientHello, err := clienthello.FromPacket(packet)
+			if err != nil {
+
+				h.logger.Log.Debug(err)
+				return
+			}
+
+			metrics.ClientHellosParsed.Inc()
+
+			source := fmt.Sprintf("%s:%d", clientHello.SrcAddr, clientHello.SrcPort)
+			destination := fmt.Sprintf("%s:%d", clientHello.DstAddr, clientHello.DstPort)
+
+			h.logger.Log.Infof("[ %s -> %s ] | %s", source, destination, clientHello.Hostname)
+
+			seenAt := packet.Metadata().Timestamp
+			h.C <- &model.Action{
+				SrcAddr:  &clientHello.SrcAddr,
+				DstAddr:  &clientHello.DstAddr,
+				SrcPort:  &clientHello.SrcPort,
+				DstPort:  &clientHello.DstPort,
+				Hostname: &clientHello.Hostname,
+				Time:     &seenAt,
 			}
 		}
 	}